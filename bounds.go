@@ -0,0 +1,753 @@
+// Copyright 2019 Adam S Levy
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package retry
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// UnboundedAttempts is the Attempts of a Bound of a Policy that may retry
+// forever.
+const UnboundedAttempts uint = math.MaxUint
+
+// UnboundedWait is the Total or Wait of a Bound that has no limit.
+const UnboundedWait time.Duration = math.MaxInt64
+
+// Bound is the worst-case behavior of a Policy used with Run, as determined
+// by Bounds.
+//
+// Attempts is the maximum number of attempts of op, Total is the maximum
+// cumulative time spent waiting between attempts, and Wait is the maximum
+// single wait. Attempts is UnboundedAttempts and Total and Wait are
+// UnboundedWait if they have no limit.
+type Bound struct {
+	Attempts uint
+	Total    time.Duration
+	Wait     time.Duration
+}
+
+// String returns a summary of b, such as "attempts: 5, total wait: 15s, max
+// wait: 8s".
+func (b Bound) String() string {
+	attempts := "unbounded"
+	if b.Attempts != UnboundedAttempts {
+		attempts = fmt.Sprint(b.Attempts)
+	}
+	return fmt.Sprintf("attempts: %v, total wait: %v, max wait: %v",
+		attempts, boundString(b.Total), boundString(b.Wait))
+}
+
+func boundString(d time.Duration) string {
+	if d == UnboundedWait {
+		return "unbounded"
+	}
+	return d.String()
+}
+
+// Bounder is implemented by custom Policies to describe their worst-case
+// behavior to Bounds. Bounds treats any other custom Policy as unbounded.
+type Bounder interface {
+	Policy
+
+	// Bounds returns the Bound of the Policy on its own.
+	Bounds() Bound
+}
+
+// Bounds returns the worst-case behavior of p when used with Run, taking the
+// limits, caps and jitter ranges of all composed Policies into account, so
+// that a Policy can be checked before it is deployed.
+//
+// The time taken by op is not known, so a time limit such as LimitTotal only
+// bounds Attempts if every wait is at least some non-zero duration. The Bound
+// of a LimitDeadline is relative to the current time of the system clock.
+//
+// Waits set by an op error with a RetryAfter are chosen by the server and are
+// not taken into account. For example, the Bound of LimitAttempts{3,
+// Constant(time.Second)} has a Wait of 1s, but Run waits as long as each
+// RetryAfter asks. Likewise, a time limit may allow more Attempts than
+// reported if a RetryAfter is shorter than the wait of the Policy.
+//
+// Otherwise, the returned Bound is never less than the actual worst case. It
+// is exact for most compositions, but may be loose for Policies whose handling
+// depends on errors or elapsed time, such as Switch, Phases and Window.
+//
+// If p or any composed Policy is a Bounder, its Bounds method is used. Other
+// custom Policies, as well as sessions returned by NewSession, are unbounded.
+func Bounds(p Policy) Bound {
+	return boundsOf(p).summarize()
+}
+
+// scanLimit is the number of attempts for which bounds are evaluated attempt
+// by attempt. Beyond it, the bounds over all attempts are used.
+const scanLimit = 1 << 16
+
+// bounds bounds the waits of a Policy for each number of attempts.
+type bounds struct {
+	// max and min bound the wait for a number of attempts no greater than
+	// retries.
+	max, min func(attempts uint) time.Duration
+	// sup and inf bound the wait for any number of attempts.
+	sup, inf time.Duration
+	// monotone is true if max never decreases as attempts increase.
+	monotone bool
+
+	// retries is the maximum number of waits before Stop is returned.
+	retries uint
+	// Once attempts exceed after, Stop is returned if total reaches limit.
+	limit time.Duration
+	after uint
+	// budget is the maximum cumulative wait.
+	budget time.Duration
+}
+
+// boundsOf returns the bounds of p.
+func boundsOf(p Policy) bounds {
+	switch p := p.(type) {
+	case Bounder:
+		b := p.Bounds()
+		retries := b.Attempts
+		if retries != UnboundedAttempts && retries > 0 {
+			retries--
+		}
+		return bounds{max: constWait(b.Wait), min: constWait(0),
+			sup: b.Wait, monotone: true, retries: retries,
+			limit: UnboundedWait, budget: b.Total}
+	case nil:
+		return stopped()
+
+	case Immediate:
+		return leaf(p.Wait, false, true)
+	case Constant:
+		if time.Duration(p) <= Stop {
+			return stopped()
+		}
+		return leaf(p.Wait, false, true)
+	case Linear:
+		return leaf(p.Wait, p.Increment > 0, p.Increment >= 0)
+	case Exponential:
+		return leaf(p.Wait, p.Initial > 0 && math.Abs(p.Multiplier) > 1,
+			p.Initial >= 0 && p.Multiplier >= 1)
+	case Fibonacci:
+		return leaf(p.Wait, p.Initial > 0, p.Initial >= 0)
+	case Polynomial:
+		return leaf(p.Wait, p.Initial > 0 && p.Degree > 0,
+			p.Initial >= 0 && p.Degree >= 0)
+	case Logarithmic:
+		return leaf(p.Wait, p.Initial > 0, p.Initial >= 0)
+	case Schedule:
+		return scheduleBounds(p)
+
+	case LimitAttempts:
+		b := boundsOf(p.Policy)
+		if p.Limit == 0 {
+			b.retries = 0
+		} else if b.retries > p.Limit-1 {
+			b.retries = p.Limit - 1
+		}
+		return b
+	case LimitTotal:
+		return boundsOf(p.Policy).limitTotal(p.Limit)
+	case LimitBudget:
+		return boundsOf(p.Policy).limitBudget(p.Limit - p.Reserve)
+	case LimitDeadline:
+		left := p.Deadline.Sub(time.Now())
+		return boundsOf(p.Policy).limitBudget(left)
+
+	case Max:
+		return boundsOf(p.Policy).transform(true,
+			func(d time.Duration) time.Duration {
+				return minDuration(d, p.Cap)
+			})
+	case Min:
+		return boundsOf(p.Policy).transform(false,
+			func(d time.Duration) time.Duration {
+				return maxDuration(d, p.Floor)
+			})
+	case Offset:
+		return boundsOf(p.Policy).transform(p.Delta <= 0,
+			func(d time.Duration) time.Duration {
+				return nonNegative(addDuration(d, p.Delta))
+			})
+	case Scale:
+		return boundsOf(p.Policy).transform(p.Factor <= 1,
+			func(d time.Duration) time.Duration {
+				return nonNegative(floatDuration(float64(d) * p.Factor))
+			})
+	case Randomize:
+		b := boundsOf(p.Policy)
+		max, min := b.max, b.min
+		b.max = func(attempts uint) time.Duration {
+			return floatDuration(float64(max(attempts)) * (1 + p.Factor))
+		}
+		b.min = func(attempts uint) time.Duration {
+			return nonNegative(floatDuration(
+				float64(min(attempts)) * (1 - p.Factor)))
+		}
+		b.sup = floatDuration(float64(b.sup) * (1 + p.Factor))
+		b.inf = nonNegative(floatDuration(float64(b.inf) * (1 - p.Factor)))
+		if p.Factor != 0 {
+			b.budget = UnboundedWait
+		}
+		return b
+	case FullJitter:
+		b := boundsOf(p.Policy)
+		b.min, b.inf = constWait(0), 0
+		return b
+	case EqualJitter:
+		b := boundsOf(p.Policy)
+		min := b.min
+		b.min = func(attempts uint) time.Duration {
+			return min(attempts) / 2
+		}
+		b.inf /= 2
+		return b
+	case DecorrelatedJitter:
+		return decorrelatedJitterBounds(p)
+	case randPolicy:
+		return boundsOf(p.policy)
+	case Window:
+		if len(p.Ranges) == 0 {
+			return stopped()
+		}
+		// The next opening is found within a week of the wait.
+		return boundsOf(p.Policy).transform(false,
+			func(d time.Duration) time.Duration {
+				return addDuration(d, 8*24*time.Hour)
+			})
+
+	case Sum:
+		return combineBounds(p, false, addDuration)
+	case LargestOf:
+		return combineBounds(p, false, maxDuration)
+	case SmallestOf:
+		return combineBounds(p, true, minDuration)
+	case Switch:
+		return switchBounds(p)
+	case Phases:
+		return phasesBounds(p)
+	}
+	return unbounded()
+}
+
+// summarize returns the Bound described by b.
+func (b bounds) summarize() Bound {
+	retries := b.retries
+	if b.limit < UnboundedWait {
+		if r := b.limitRetries(); r < retries {
+			retries = r
+		}
+	}
+	if retries == 0 {
+		return Bound{Attempts: 1}
+	}
+	bound := Bound{
+		Attempts: retries,
+		Wait:     b.maxWait(retries),
+		Total:    b.sumMax(retries),
+	}
+	if retries != UnboundedAttempts {
+		bound.Attempts++
+	}
+	if b.budget < bound.Total {
+		bound.Total = b.budget
+	}
+	if b.limit < UnboundedWait {
+		// All waits after the first b.after attempts, but the last, end
+		// before the limit.
+		total := addDuration(b.sumMax(b.after),
+			addDuration(b.limit, bound.Wait))
+		if total < bound.Total {
+			bound.Total = total
+		}
+	}
+	if bound.Total < bound.Wait {
+		bound.Wait = bound.Total
+	}
+	return bound
+}
+
+// limitRetries returns the maximum number of waits before the total time
+// reaches b.limit, assuming op takes no time.
+func (b bounds) limitRetries() uint {
+	var total time.Duration
+	for attempts := uint(1); attempts <= b.retries; attempts++ {
+		if attempts > b.after && total >= b.limit {
+			return attempts - 1
+		}
+		if attempts > scanLimit && attempts > b.after {
+			if b.inf <= 0 {
+				return UnboundedAttempts
+			}
+			n := uint(addDuration(b.limit-total, b.inf-1) / b.inf)
+			if n > UnboundedAttempts-attempts {
+				return UnboundedAttempts
+			}
+			return attempts - 1 + n
+		}
+		total = addDuration(total, nonNegative(b.min(attempts)))
+	}
+	return b.retries
+}
+
+// maxWait returns the largest wait within the first retries attempts.
+func (b bounds) maxWait(retries uint) time.Duration {
+	if b.monotone {
+		if retries == UnboundedAttempts {
+			return nonNegative(b.sup)
+		}
+		return nonNegative(b.max(retries))
+	}
+	var max time.Duration
+	for attempts := uint(1); attempts <= retries; attempts++ {
+		if attempts > scanLimit {
+			return maxDuration(max, b.sup)
+		}
+		max = maxDuration(max, b.max(attempts))
+	}
+	return max
+}
+
+// sumMax returns the sum of the largest waits of the first retries attempts.
+func (b bounds) sumMax(retries uint) time.Duration {
+	var sum time.Duration
+	for attempts := uint(1); attempts <= retries; attempts++ {
+		if sum == UnboundedWait {
+			break
+		}
+		if attempts > scanLimit {
+			wait := b.sup
+			if b.monotone && retries != UnboundedAttempts {
+				wait = b.max(retries)
+			}
+			return addDuration(sum,
+				mulDuration(nonNegative(wait), retries-attempts+1))
+		}
+		sum = addDuration(sum, nonNegative(b.max(attempts)))
+	}
+	return sum
+}
+
+// limitTotal returns b limited to waiting while the total time is less than
+// limit.
+func (b bounds) limitTotal(limit time.Duration) bounds {
+	if b.after > 0 || limit < b.limit {
+		b.limit, b.after = nonNegative(limit), 0
+	}
+	return b
+}
+
+// limitBudget returns b limited to waits that end within left of the total
+// time.
+func (b bounds) limitBudget(left time.Duration) bounds {
+	left = nonNegative(left)
+	b = b.limitTotal(left)
+	if left < b.budget {
+		b.budget = left
+	}
+	max := b.max
+	b.max = func(attempts uint) time.Duration {
+		return minDuration(max(attempts), left)
+	}
+	b.sup = minDuration(b.sup, left)
+	return b
+}
+
+// transform returns b with each wait bound passed through f, which must never
+// decrease as its argument increases. If keepBudget is false, f may increase
+// waits, so b.budget no longer applies.
+func (b bounds) transform(keepBudget bool,
+	f func(time.Duration) time.Duration) bounds {
+
+	max, min := b.max, b.min
+	b.max = func(attempts uint) time.Duration { return f(max(attempts)) }
+	b.min = func(attempts uint) time.Duration { return f(min(attempts)) }
+	b.sup, b.inf = f(b.sup), f(b.inf)
+	if !keepBudget {
+		b.budget = UnboundedWait
+	}
+	return b
+}
+
+// leaf returns the bounds of a Policy that never stops and whose waits are
+// given by wait. If grows is true, the waits grow without limit, otherwise
+// none exceeds the first. If monotone is true, the waits never decrease.
+func leaf(wait func(uint, time.Duration) time.Duration,
+	grows, monotone bool) bounds {
+
+	w := func(attempts uint) time.Duration { return wait(attempts, 0) }
+	b := bounds{max: w, min: w, sup: nonNegative(w(1)),
+		monotone: monotone, retries: UnboundedAttempts,
+		limit: UnboundedWait, budget: UnboundedWait}
+	if grows {
+		b.sup = UnboundedWait
+	}
+	if monotone {
+		b.inf = nonNegative(w(1))
+	}
+	return b
+}
+
+// stopped returns the bounds of a Policy that always returns Stop.
+func stopped() bounds {
+	return bounds{max: constWait(0), min: constWait(0), monotone: true,
+		limit: UnboundedWait}
+}
+
+// unbounded returns the bounds of a Policy that may wait for any duration
+// and never stops.
+func unbounded() bounds {
+	return bounds{max: constWait(UnboundedWait), min: constWait(0),
+		sup: UnboundedWait, monotone: true, retries: UnboundedAttempts,
+		limit: UnboundedWait, budget: UnboundedWait}
+}
+
+func scheduleBounds(s Schedule) bounds {
+	n := uint(len(s.Waits))
+	var tail bounds
+	switch {
+	case s.Then != nil:
+		tail = boundsOf(s.Then)
+	case n == 0:
+		return stopped()
+	default:
+		tail = leaf(Constant(s.Waits[n-1]).Wait, false, true)
+	}
+	b := tail
+	b.max = func(attempts uint) time.Duration {
+		if attempts > n {
+			return tail.max(attempts - n)
+		}
+		return s.Waits[attempts-1]
+	}
+	b.min = func(attempts uint) time.Duration {
+		if attempts > n {
+			return tail.min(attempts - n)
+		}
+		return s.Waits[attempts-1]
+	}
+	var sum time.Duration
+	for i, wait := range s.Waits {
+		b.sup = maxDuration(b.sup, wait)
+		b.inf = minDuration(b.inf, wait)
+		if i > 0 && wait < s.Waits[i-1] {
+			b.monotone = false
+		}
+		sum = addDuration(sum, wait)
+	}
+	if tail.retries > 0 && tail.max(1) < s.Waits[n-1] {
+		b.monotone = false
+	}
+	b.retries = addRetries(n, tail.retries)
+	b.after = addRetries(n, tail.after)
+	b.budget = addDuration(sum, tail.budget)
+	return b
+}
+
+func decorrelatedJitterBounds(d DecorrelatedJitter) bounds {
+	limit := d.Cap
+	if limit <= 0 {
+		limit = UnboundedWait
+	}
+	base := nonNegative(d.Base)
+	// The wait after n attempts is at most 3^n * d.Base.
+	max := Exponential{mulDuration(base, 3), 3}
+	b := bounds{
+		max: func(attempts uint) time.Duration {
+			return minDuration(maxDuration(max.Wait(attempts, 0), base),
+				limit)
+		},
+		min:      constWait(minDuration(base, limit)),
+		sup:      minDuration(base, limit),
+		inf:      minDuration(base, limit),
+		monotone: true,
+		retries:  UnboundedAttempts,
+		limit:    UnboundedWait,
+		budget:   UnboundedWait,
+	}
+	if base > 0 {
+		b.sup = limit
+	}
+	return b
+}
+
+// combineBounds returns the bounds of a Policy that combines the waits of ps
+// using f, and which stops if any of ps stops. If smallest is true, the
+// combined wait is no greater than any of the waits of ps.
+func combineBounds(ps []Policy, smallest bool,
+	f func(a, b time.Duration) time.Duration) bounds {
+
+	if len(ps) == 0 {
+		return stopped()
+	}
+	bs := make([]bounds, len(ps))
+	for i, p := range ps {
+		bs[i] = boundsOf(p)
+	}
+	b := bs[0]
+	b.max = func(attempts uint) time.Duration {
+		wait := bs[0].max(attempts)
+		for _, b := range bs[1:] {
+			wait = f(wait, b.max(attempts))
+		}
+		return wait
+	}
+	b.min = func(attempts uint) time.Duration {
+		wait := bs[0].min(attempts)
+		for _, b := range bs[1:] {
+			wait = f(wait, b.min(attempts))
+		}
+		return wait
+	}
+	for _, c := range bs[1:] {
+		b.sup, b.inf = f(b.sup, c.sup), f(b.inf, c.inf)
+		b.monotone = b.monotone && c.monotone
+		if c.retries < b.retries {
+			b.retries = c.retries
+		}
+		if c.limit < UnboundedWait && (b.limit == UnboundedWait ||
+			c.after < b.after ||
+			(c.after == b.after && c.limit < b.limit)) {
+			b.limit, b.after = c.limit, c.after
+		}
+		if smallest {
+			b.budget = minDuration(b.budget, c.budget)
+		} else {
+			b.budget = addDuration(b.budget, c.budget)
+		}
+	}
+	return b
+}
+
+func switchBounds(s Switch) bounds {
+	var bs []bounds
+	for _, c := range s.Cases {
+		bs = append(bs, boundsOf(c.Policy))
+	}
+	if s.Default != nil {
+		bs = append(bs, boundsOf(s.Default))
+	}
+	// Each branch counts its own attempts, so any branch may be at any of
+	// its attempts up to the total number of attempts. The branch of the
+	// latest error stops Run once its own limit is reached.
+	b := unionOf(bs)
+	b.limit = 0
+	for _, c := range bs {
+		if c.retries == 0 {
+			continue
+		}
+		if c.after > 0 {
+			b.limit = UnboundedWait
+		}
+		b.limit = maxDuration(b.limit, c.limit)
+	}
+	return b
+}
+
+func phasesBounds(p Phases) bounds {
+	var phases []bounds
+	var offsets []uint
+	var offset uint
+	exact := true
+	for _, phase := range p {
+		b := boundsOf(phase.Policy)
+		if phase.Attempts > 0 && phase.Attempts < b.retries {
+			b.retries = phase.Attempts
+		}
+		// The time limits of the Policy of a Phase are rebased to the
+		// start of the Phase, so they do not limit Phases. However, the
+		// Duration of the Phase still limits its cumulative wait.
+		b.budget = minDuration(b.budget, b.sumMax(b.retries))
+		if phase.Duration > 0 {
+			b.budget = minDuration(b.budget, addDuration(phase.Duration,
+				b.maxWait(b.retries)))
+		}
+		b.limit, b.after = UnboundedWait, 0
+		phases = append(phases, b)
+		offsets = append(offsets, offset)
+		if phase.Attempts == 0 && phase.Duration <= 0 {
+			// Later Phases are never used.
+			break
+		}
+		if phase.Attempts > 0 && b.retries < phase.Attempts {
+			// The Policy stops before the Phase ends.
+			break
+		}
+		if phase.Duration > 0 {
+			exact = false
+		}
+		offset = addRetries(offset, b.retries)
+	}
+	if len(phases) == 0 {
+		return stopped()
+	}
+
+	b := bounds{monotone: true, limit: UnboundedWait}
+	for _, phase := range phases {
+		b.retries = addRetries(b.retries, phase.retries)
+		b.budget = addDuration(b.budget, phase.budget)
+	}
+	if !exact {
+		union := unionOf(phases)
+		union.retries, union.budget = b.retries, b.budget
+		return union
+	}
+
+	// The Phase of each attempt is known.
+	b.max = func(attempts uint) time.Duration {
+		i := phaseOf(offsets, attempts)
+		return phases[i].max(attempts - offsets[i])
+	}
+	b.min = func(attempts uint) time.Duration {
+		i := phaseOf(offsets, attempts)
+		return phases[i].min(attempts - offsets[i])
+	}
+	b.inf = UnboundedWait
+	for _, phase := range phases {
+		b.sup = maxDuration(b.sup, phase.sup)
+		b.inf = minDuration(b.inf, phase.inf)
+	}
+	b.monotone = false
+	return b
+}
+
+// phaseOf returns the index of the last of offsets before attempts.
+func phaseOf(offsets []uint, attempts uint) int {
+	i := len(offsets) - 1
+	for i > 0 && offsets[i] >= attempts {
+		i--
+	}
+	return i
+}
+
+// unionOf returns the bounds of a Policy that uses any one of bs with any
+// number of attempts up to its own, such that the retries of all of bs add
+// up.
+func unionOf(bs []bounds) bounds {
+	b := bounds{monotone: true, inf: UnboundedWait,
+		limit: UnboundedWait}
+	var maxes, mins []func(uint) time.Duration
+	for _, c := range bs {
+		if c.retries == 0 {
+			continue
+		}
+		maxes = append(maxes, prefixBound(c, c.max, maxDuration, c.sup))
+		mins = append(mins, prefixBound(c, c.min, minDuration, c.inf))
+		b.sup = maxDuration(b.sup, c.sup)
+		b.inf = minDuration(b.inf, c.inf)
+		b.retries = addRetries(b.retries, c.retries)
+		// The attempts of each of bs are counted separately, so the
+		// cumulative wait is at most the sum of their own.
+		b.budget = addDuration(b.budget,
+			minDuration(c.budget, c.sumMax(c.retries)))
+	}
+	if b.retries == 0 {
+		return stopped()
+	}
+	b.max = func(attempts uint) time.Duration {
+		var wait time.Duration
+		for _, max := range maxes {
+			wait = maxDuration(wait, max(attempts))
+		}
+		return wait
+	}
+	b.min = func(attempts uint) time.Duration {
+		wait := UnboundedWait
+		for _, min := range mins {
+			wait = minDuration(wait, min(attempts))
+		}
+		return wait
+	}
+	return b
+}
+
+// prefixBound returns a function that combines wait(1) through wait(n) using
+// f, where n is the lesser of attempts and b.retries. Beyond scanLimit
+// attempts, all is combined instead.
+func prefixBound(b bounds, wait func(uint) time.Duration,
+	f func(a, b time.Duration) time.Duration,
+	all time.Duration) func(uint) time.Duration {
+
+	var memo []time.Duration
+	return func(attempts uint) time.Duration {
+		if attempts > b.retries {
+			attempts = b.retries
+		}
+		if attempts > scanLimit {
+			return f(all, wait(1))
+		}
+		for uint(len(memo)) < attempts {
+			next := wait(uint(len(memo)) + 1)
+			if len(memo) > 0 {
+				next = f(memo[len(memo)-1], next)
+			}
+			memo = append(memo, next)
+		}
+		return memo[attempts-1]
+	}
+}
+
+func constWait(d time.Duration) func(uint) time.Duration {
+	return func(uint) time.Duration { return d }
+}
+
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// mulDuration returns d*n, or math.MaxInt64 if any integer overflow occurs.
+// The caller must ensure that d is not negative.
+func mulDuration(d time.Duration, n uint) time.Duration {
+	if n > math.MaxInt64 || (n > 0 && d > UnboundedWait/time.Duration(n)) {
+		if d == 0 {
+			return 0
+		}
+		return UnboundedWait
+	}
+	return d * time.Duration(n)
+}
+
+// addRetries returns a+b, or UnboundedAttempts if any integer overflow
+// occurs.
+func addRetries(a, b uint) uint {
+	if a > UnboundedAttempts-b {
+		return UnboundedAttempts
+	}
+	return a + b
+}
@@ -0,0 +1,210 @@
+// Copyright 2019 Adam S Levy
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package retry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var boundsTests = []struct {
+	Name   string
+	Policy Policy
+	Bound  Bound
+}{{
+	Name:   "Constant",
+	Policy: Constant(time.Second),
+	Bound:  Bound{UnboundedAttempts, UnboundedWait, time.Second},
+}, {
+	Name:   "Immediate",
+	Policy: Immediate{},
+	Bound:  Bound{UnboundedAttempts, 0, 0},
+}, {
+	Name:   "Stop",
+	Policy: Constant(Stop),
+	Bound:  Bound{1, 0, 0},
+}, {
+	Name:   "Exponential",
+	Policy: Exponential{time.Second, 2},
+	Bound:  Bound{UnboundedAttempts, UnboundedWait, UnboundedWait},
+}, {
+	Name:   "LimitAttempts",
+	Policy: LimitAttempts{5, Exponential{time.Second, 2}},
+	Bound:  Bound{5, 15 * time.Second, 8 * time.Second},
+}, {
+	Name:   "LimitAttempts/large",
+	Policy: LimitAttempts{1 << 20, Constant(time.Millisecond)},
+	Bound:  Bound{1 << 20, (1<<20 - 1) * time.Millisecond, time.Millisecond},
+}, {
+	Name:   "LimitTotal",
+	Policy: LimitTotal{time.Minute, Constant(10 * time.Second)},
+	Bound:  Bound{7, time.Minute, 10 * time.Second},
+}, {
+	Name:   "LimitTotal/Immediate",
+	Policy: LimitTotal{time.Minute, Immediate{}},
+	Bound:  Bound{UnboundedAttempts, 0, 0},
+}, {
+	Name:   "LimitTotal/FullJitter",
+	Policy: LimitTotal{time.Minute, FullJitter{Constant(time.Second)}},
+	Bound:  Bound{UnboundedAttempts, time.Minute + time.Second, time.Second},
+}, {
+	Name: "LimitBudget",
+	Policy: LimitBudget{time.Minute, 5 * time.Second, true,
+		Constant(20 * time.Second)},
+	Bound: Bound{4, 55 * time.Second, 20 * time.Second},
+}, {
+	Name:   "Max",
+	Policy: LimitAttempts{6, Max{10 * time.Second, Exponential{time.Second, 2}}},
+	Bound:  Bound{6, 25 * time.Second, 10 * time.Second},
+}, {
+	Name: "Randomize",
+	Policy: LimitAttempts{3, Randomize{.5,
+		Constant(time.Minute)}},
+	Bound: Bound{3, 3 * time.Minute, 90 * time.Second},
+}, {
+	Name: "Randomize/WithRand",
+	Policy: LimitAttempts{3, Randomize{.5, Constant(time.Minute)}.
+		WithRand(nil, TruncatedNormal{.1})},
+	Bound: Bound{3, 3 * time.Minute, 90 * time.Second},
+}, {
+	Name: "Max/Randomize",
+	Policy: LimitAttempts{3, Max{time.Minute, Randomize{.5,
+		Constant(time.Minute)}}},
+	Bound: Bound{3, 2 * time.Minute, time.Minute},
+}, {
+	Name: "EqualJitter",
+	Policy: LimitAttempts{3, EqualJitter{
+		Linear{time.Second, time.Second}}},
+	Bound: Bound{3, 3 * time.Second, 2 * time.Second},
+}, {
+	Name: "DecorrelatedJitter",
+	Policy: LimitAttempts{4,
+		DecorrelatedJitter{time.Second, 10 * time.Second}},
+	Bound: Bound{4, 22 * time.Second, 10 * time.Second},
+}, {
+	Name:   "Schedule",
+	Policy: Schedule{[]time.Duration{time.Second, 5 * time.Second}, Constant(Stop)},
+	Bound:  Bound{3, 6 * time.Second, 5 * time.Second},
+}, {
+	Name: "Schedule/Then",
+	Policy: Schedule{[]time.Duration{time.Minute},
+		LimitAttempts{3, Constant(time.Second)}},
+	Bound: Bound{4, time.Minute + 2*time.Second, time.Minute},
+}, {
+	Name: "Schedule/LimitTotal",
+	Policy: LimitTotal{time.Minute,
+		Schedule{Waits: []time.Duration{time.Second, 20 * time.Second}}},
+	Bound: Bound{5, 61 * time.Second, 20 * time.Second},
+}, {
+	Name: "Sum",
+	Policy: Sum{LimitAttempts{3, Constant(time.Second)},
+		Linear{time.Second, time.Second}},
+	Bound: Bound{3, 5 * time.Second, 3 * time.Second},
+}, {
+	Name: "SmallestOf",
+	Policy: SmallestOf{LimitAttempts{4, Exponential{time.Second, 2}},
+		Constant(3 * time.Second)},
+	Bound: Bound{4, 6 * time.Second, 3 * time.Second},
+}, {
+	Name: "Switch",
+	Policy: Switch{Cases: []Case{
+		{MatchIs(errTestReset), LimitAttempts{3, Constant(time.Second)}},
+	}, Default: LimitAttempts{2, Constant(time.Minute)}},
+	Bound: Bound{4, time.Minute + 2*time.Second, time.Minute},
+}, {
+	Name: "Switch/unbounded",
+	Policy: Switch{Cases: []Case{
+		{MatchIs(errTestReset), LimitAttempts{3, Constant(time.Second)}},
+	}, Default: Constant(time.Minute)},
+	Bound: Bound{UnboundedAttempts, UnboundedWait, time.Minute},
+}, {
+	Name: "Phases",
+	Policy: Phases{
+		{3, 0, Constant(time.Second)},
+		{0, 0, LimitAttempts{3, Exponential{time.Minute, 2}}},
+	},
+	Bound: Bound{6, 3*time.Second + 3*time.Minute, 2 * time.Minute},
+}, {
+	Name: "Phases/Duration",
+	Policy: Phases{
+		{0, time.Minute, Constant(time.Second)},
+		{0, 0, LimitAttempts{3, Constant(time.Minute)}},
+	},
+	Bound: Bound{UnboundedAttempts, 3*time.Minute + time.Second, time.Minute},
+}, {
+	Name: "Phases/LimitAttempts",
+	Policy: LimitAttempts{4, Phases{
+		{0, time.Minute, Constant(time.Second)},
+		{0, 0, Constant(time.Minute)},
+	}},
+	Bound: Bound{4, 3 * time.Minute, time.Minute},
+}, {
+	Name: "Window",
+	Policy: LimitAttempts{2, Window{Ranges: []TimeRange{{nil, 0, time.Hour}},
+		Policy: Constant(time.Second)}},
+	Bound: Bound{2, 8*24*time.Hour + time.Second, 8*24*time.Hour + time.Second},
+}, {
+	Name:   "Bounder",
+	Policy: LimitTotal{time.Hour, bounderPolicy{Bound{3, time.Minute, time.Second}}},
+	Bound:  Bound{3, 2 * time.Second, time.Second},
+}, {
+	Name:   "custom",
+	Policy: LimitAttempts{3, countPolicy{}},
+	Bound:  Bound{3, UnboundedWait, UnboundedWait},
+}}
+
+func TestBounds(t *testing.T) {
+	for _, test := range boundsTests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			assert.Equal(t, test.Bound, Bounds(test.Policy))
+		})
+	}
+	t.Run("LimitDeadline", func(t *testing.T) {
+		assert := assert.New(t)
+		// The Bound is relative to the system clock, so Total is
+		// slightly less than the time until Deadline.
+		policy := LimitDeadline{time.Now().Add(time.Minute - time.Second),
+			Constant(10 * time.Second)}
+		bound := Bounds(policy)
+		assert.Equal(uint(7), bound.Attempts)
+		assert.Equal(10*time.Second, bound.Wait)
+		assert.True(58*time.Second < bound.Total &&
+			bound.Total <= 59*time.Second, bound.Total)
+	})
+	t.Run("String", func(t *testing.T) {
+		assert := assert.New(t)
+		assert.Equal("attempts: 5, total wait: 15s, max wait: 8s",
+			Bound{5, 15 * time.Second, 8 * time.Second}.String())
+		assert.Equal("attempts: unbounded, total wait: unbounded, "+
+			"max wait: 1m0s",
+			Bound{UnboundedAttempts, UnboundedWait, time.Minute}.String())
+	})
+}
+
+// bounderPolicy is a custom Policy that reports Bound from Bounds.
+type bounderPolicy struct{ Bound Bound }
+
+func (bounderPolicy) Wait(uint, time.Duration) time.Duration { return time.Second }
+
+func (b bounderPolicy) Bounds() Bound { return b.Bound }
@@ -0,0 +1,187 @@
+// Copyright 2019 Adam S Levy
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package retry
+
+import "time"
+
+// Clock provides the current time and timers to Run.
+//
+// By default Run uses the system clock backed by the time package. Tests may
+// use WithClock to pass a Clock that is controlled manually, such as
+// retrytest.FakeClock.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// NewTimer returns a Timer that will send the current time on its
+	// channel after at least duration d.
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is the subset of the time.Timer API used by Run.
+type Timer interface {
+	Reset(time.Duration) bool
+	Stop() bool
+	GetC() <-chan time.Time
+}
+
+// systemClock is a Clock backed by the time package.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return (*timeTimer)(time.NewTimer(d))
+}
+
+type timeTimer time.Timer
+
+func (t *timeTimer) Reset(d time.Duration) bool {
+	return (*time.Timer)(t).Reset(d)
+}
+func (t *timeTimer) Stop() bool {
+	return (*time.Timer)(t).Stop()
+}
+func (t *timeTimer) GetC() <-chan time.Time {
+	return t.C
+}
+
+// clockPolicy is a LimitDeadline or Window that obtains the current time from
+// clock rather than from the system clock.
+type clockPolicy struct {
+	policy timedPolicy
+	clock  Clock
+}
+
+// timedPolicy is implemented by the Policies of this package that depend on
+// the current time.
+type timedPolicy interface {
+	Policy
+	waitClock(clock Clock, err error,
+		attempts uint, total time.Duration) (time.Duration, bool)
+}
+
+func (p clockPolicy) Wait(attempts uint, total time.Duration) time.Duration {
+	return p.WaitErr(nil, attempts, total)
+}
+
+func (p clockPolicy) WaitErr(err error,
+	attempts uint, total time.Duration) time.Duration {
+
+	wait, _ := p.waitExtended(err, attempts, total)
+	return wait
+}
+
+func (p clockPolicy) waitExtended(err error,
+	attempts uint, total time.Duration) (time.Duration, bool) {
+
+	return p.policy.waitClock(p.clock, err, attempts, total)
+}
+
+func (p clockPolicy) NewSession() Policy {
+	p.policy = NewSession(p.policy).(timedPolicy)
+	return p
+}
+
+// useClock returns a copy of p in which each LimitDeadline and Window obtains
+// the current time from clock. Run calls it with the Clock passed with
+// WithClock. Custom Policies are returned as is, so a LimitDeadline or Window
+// wrapped by one uses the system clock.
+func useClock(p Policy, clock Clock) Policy {
+	switch p := p.(type) {
+	case LimitDeadline:
+		p.Policy = useClock(p.Policy, clock)
+		return clockPolicy{p, clock}
+	case Window:
+		p.Policy = useClock(p.Policy, clock)
+		return clockPolicy{p, clock}
+	case LimitAttempts:
+		p.Policy = useClock(p.Policy, clock)
+		return p
+	case LimitTotal:
+		p.Policy = useClock(p.Policy, clock)
+		return p
+	case LimitBudget:
+		p.Policy = useClock(p.Policy, clock)
+		return p
+	case Max:
+		p.Policy = useClock(p.Policy, clock)
+		return p
+	case Min:
+		p.Policy = useClock(p.Policy, clock)
+		return p
+	case Offset:
+		p.Policy = useClock(p.Policy, clock)
+		return p
+	case Scale:
+		p.Policy = useClock(p.Policy, clock)
+		return p
+	case Randomize:
+		p.Policy = useClock(p.Policy, clock)
+		return p
+	case FullJitter:
+		p.Policy = useClock(p.Policy, clock)
+		return p
+	case EqualJitter:
+		p.Policy = useClock(p.Policy, clock)
+		return p
+	case randPolicy:
+		p.policy = useClock(p.policy, clock).(randomizer)
+		return p
+	case Schedule:
+		if p.Then != nil {
+			p.Then = useClock(p.Then, clock)
+		}
+		return p
+	case Sum:
+		return Sum(useClocks(p, clock))
+	case LargestOf:
+		return LargestOf(useClocks(p, clock))
+	case SmallestOf:
+		return SmallestOf(useClocks(p, clock))
+	case Switch:
+		cases := make([]Case, len(p.Cases))
+		for i, c := range p.Cases {
+			c.Policy = useClock(c.Policy, clock)
+			cases[i] = c
+		}
+		p.Cases = cases
+		if p.Default != nil {
+			p.Default = useClock(p.Default, clock)
+		}
+		return p
+	case Phases:
+		phases := make(Phases, len(p))
+		for i, phase := range p {
+			phase.Policy = useClock(phase.Policy, clock)
+			phases[i] = phase
+		}
+		return phases
+	}
+	return p
+}
+
+func useClocks(ps []Policy, clock Clock) []Policy {
+	clocked := make([]Policy, len(ps))
+	for i, p := range ps {
+		clocked[i] = useClock(p, clock)
+	}
+	return clocked
+}
@@ -0,0 +1,288 @@
+// Copyright 2019 Adam S Levy
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package retry
+
+import (
+	"math"
+	"time"
+
+	"github.com/JohnCGriffin/overflow"
+)
+
+// Min wraps a Policy such that wait time is at least Floor.
+type Min struct {
+	Floor time.Duration
+	Policy
+}
+
+// Wait returns the maximum between m.Floor and the result of
+// m.Policy.Wait(attempts, total). If the result is Stop, it is returned
+// directly.
+func (m Min) Wait(attempts uint, total time.Duration) time.Duration {
+	return m.WaitErr(nil, attempts, total)
+}
+
+// WaitErr is like Wait but passes err to m.Policy if it is an
+// ErrorPolicy.
+func (m Min) WaitErr(err error,
+	attempts uint, total time.Duration) time.Duration {
+
+	wait, _ := m.waitExtended(err, attempts, total)
+	return wait
+}
+
+func (m Min) waitExtended(err error,
+	attempts uint, total time.Duration) (time.Duration, bool) {
+
+	wait, extended := waitExtended(m.Policy, err, attempts, total)
+	if wait > Stop && wait < m.Floor {
+		return m.Floor, false
+	}
+	return wait, extended
+}
+
+// NewSession returns a Min wrapping a new session of m.Policy.
+func (m Min) NewSession() Policy {
+	return Min{m.Floor, NewSession(m.Policy)}
+}
+
+// Offset wraps a Policy such that Delta is added to its wait time.
+type Offset struct {
+	Delta time.Duration
+	Policy
+}
+
+// Wait returns the result of o.Policy.Wait(attempts, total) plus o.Delta,
+// or math.MaxInt64 if any integer overflow occurs. A negative o.Delta does not
+// reduce the wait below 0. If the result of o.Policy is Stop, it is returned
+// directly.
+//
+// If WaitErr is passed an error with a RetryAfter, the wait is returned
+// without adding o.Delta.
+func (o Offset) Wait(attempts uint, total time.Duration) time.Duration {
+	return o.WaitErr(nil, attempts, total)
+}
+
+// WaitErr is like Wait but passes err to o.Policy if it is an
+// ErrorPolicy.
+func (o Offset) WaitErr(err error,
+	attempts uint, total time.Duration) time.Duration {
+
+	wait, _ := o.waitExtended(err, attempts, total)
+	return wait
+}
+
+func (o Offset) waitExtended(err error,
+	attempts uint, total time.Duration) (time.Duration, bool) {
+
+	wait, extended := waitExtended(o.Policy, err, attempts, total)
+	if _, ok := RetryAfterDuration(err); ok || wait <= Stop {
+		return wait, extended
+	}
+	wait = addDuration(wait, o.Delta)
+	if wait < 0 {
+		return 0, extended
+	}
+	return wait, extended
+}
+
+// NewSession returns an Offset wrapping a new session of o.Policy.
+func (o Offset) NewSession() Policy {
+	return Offset{o.Delta, NewSession(o.Policy)}
+}
+
+// Scale wraps a Policy such that its wait time is multiplied by Factor, for
+// example to back off more during incidents.
+type Scale struct {
+	Factor float64
+	Policy
+}
+
+// Wait returns the result of s.Policy.Wait(attempts, total) multiplied by
+// s.Factor, up to the largest value that does not overflow. A negative
+// s.Factor results in 0. If the result of s.Policy is Stop, it is returned
+// directly.
+//
+// If WaitErr is passed an error with a RetryAfter, the wait is returned
+// without scaling it.
+func (s Scale) Wait(attempts uint, total time.Duration) time.Duration {
+	return s.WaitErr(nil, attempts, total)
+}
+
+// WaitErr is like Wait but passes err to s.Policy if it is an
+// ErrorPolicy.
+func (s Scale) WaitErr(err error,
+	attempts uint, total time.Duration) time.Duration {
+
+	wait, _ := s.waitExtended(err, attempts, total)
+	return wait
+}
+
+func (s Scale) waitExtended(err error,
+	attempts uint, total time.Duration) (time.Duration, bool) {
+
+	wait, extended := waitExtended(s.Policy, err, attempts, total)
+	if _, ok := RetryAfterDuration(err); ok || wait <= 0 {
+		return wait, extended
+	}
+	scaled := float64(wait) * s.Factor
+	if scaled >= math.MaxInt64 {
+		return math.MaxInt64, extended
+	}
+	if scaled <= 0 {
+		return 0, extended
+	}
+	return time.Duration(scaled), extended
+}
+
+// NewSession returns a Scale wrapping a new session of s.Policy.
+func (s Scale) NewSession() Policy {
+	return Scale{s.Factor, NewSession(s.Policy)}
+}
+
+// Sum is a Policy whose wait time is the sum of the wait times of all of its
+// Policies. If any of the Policies returns Stop, or there are no Policies,
+// Stop is returned.
+type Sum []Policy
+
+// Wait returns the sum of the results of Wait of each Policy in s, or
+// math.MaxInt64 if any integer overflow occurs.
+//
+// If WaitErr is passed an error with a RetryAfter, the waits are not added,
+// since each is the RetryAfter wait, and the largest is returned instead.
+func (s Sum) Wait(attempts uint, total time.Duration) time.Duration {
+	return s.WaitErr(nil, attempts, total)
+}
+
+// WaitErr is like Wait but passes err to each Policy that is an ErrorPolicy.
+func (s Sum) WaitErr(err error,
+	attempts uint, total time.Duration) time.Duration {
+
+	if len(s) == 0 {
+		return Stop
+	}
+	_, retryAfter := RetryAfterDuration(err)
+	var sum time.Duration
+	for _, p := range s {
+		wait := WaitErr(p, err, attempts, total)
+		if wait <= Stop {
+			return Stop
+		}
+		if retryAfter {
+			if wait > sum {
+				sum = wait
+			}
+			continue
+		}
+		sum = addDuration(sum, wait)
+	}
+	return sum
+}
+
+// NewSession returns a Sum of new sessions of each Policy in s.
+func (s Sum) NewSession() Policy {
+	return Sum(newSessions(s))
+}
+
+// LargestOf is a Policy whose wait time is the largest of the wait times of
+// all of its Policies. If any of the Policies returns Stop, or there are no
+// Policies, Stop is returned.
+type LargestOf []Policy
+
+// Wait returns the largest of the results of Wait of each Policy in l.
+func (l LargestOf) Wait(attempts uint, total time.Duration) time.Duration {
+	return l.WaitErr(nil, attempts, total)
+}
+
+// WaitErr is like Wait but passes err to each Policy that is an ErrorPolicy.
+func (l LargestOf) WaitErr(err error,
+	attempts uint, total time.Duration) time.Duration {
+
+	return pickWait(l, err, attempts, total,
+		func(wait, picked time.Duration) bool { return wait > picked })
+}
+
+// NewSession returns a LargestOf of new sessions of each Policy in l.
+func (l LargestOf) NewSession() Policy {
+	return LargestOf(newSessions(l))
+}
+
+// SmallestOf is a Policy whose wait time is the smallest of the wait times of
+// all of its Policies. If any of the Policies returns Stop, or there are no
+// Policies, Stop is returned.
+type SmallestOf []Policy
+
+// Wait returns the smallest of the results of Wait of each Policy in s.
+func (s SmallestOf) Wait(attempts uint, total time.Duration) time.Duration {
+	return s.WaitErr(nil, attempts, total)
+}
+
+// WaitErr is like Wait but passes err to each Policy that is an ErrorPolicy.
+func (s SmallestOf) WaitErr(err error,
+	attempts uint, total time.Duration) time.Duration {
+
+	return pickWait(s, err, attempts, total,
+		func(wait, picked time.Duration) bool { return wait < picked })
+}
+
+// NewSession returns a SmallestOf of new sessions of each Policy in s.
+func (s SmallestOf) NewSession() Policy {
+	return SmallestOf(newSessions(s))
+}
+
+// pickWait returns the wait of the Policy in ps for which better returns true
+// against all others, or Stop if any Policy returns Stop or ps is empty.
+func pickWait(ps []Policy, err error, attempts uint, total time.Duration,
+	better func(wait, picked time.Duration) bool) time.Duration {
+
+	picked := Stop
+	for i, p := range ps {
+		wait := WaitErr(p, err, attempts, total)
+		if wait <= Stop {
+			return Stop
+		}
+		if i == 0 || better(wait, picked) {
+			picked = wait
+		}
+	}
+	return picked
+}
+
+// newSessions returns new sessions of each Policy in ps.
+func newSessions(ps []Policy) []Policy {
+	sessions := make([]Policy, len(ps))
+	for i, p := range ps {
+		sessions[i] = NewSession(p)
+	}
+	return sessions
+}
+
+// addDuration returns a+b, or the nearest of math.MinInt64 or math.MaxInt64
+// if any integer overflow occurs.
+func addDuration(a, b time.Duration) time.Duration {
+	if sum, ok := overflow.Add64(int64(a), int64(b)); ok {
+		return time.Duration(sum)
+	}
+	if b < 0 {
+		return math.MinInt64
+	}
+	return math.MaxInt64
+}
@@ -0,0 +1,238 @@
+// Copyright 2019 Adam S Levy
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package retry
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Attempt describes the current attempt of op. RunContext and RunValueContext
+// add it to the context.Context passed to op. Use AttemptFromContext to
+// retrieve it, for example to set an idempotency key or to log differently on
+// the final attempt.
+type Attempt struct {
+	// Number is the 1-based number of this attempt.
+	Number uint
+
+	// Start is the time that Run was called.
+	Start time.Time
+
+	// Elapsed is the time elapsed since Start when this attempt started.
+	Elapsed time.Duration
+
+	// PrevErr is the filtered error of the previous attempt, or nil for
+	// the first attempt.
+	PrevErr error
+
+	// PrevWait is the wait prior to this attempt, or 0 for the first
+	// attempt.
+	PrevWait time.Duration
+
+	// final predicts whether this is the final attempt. See Final.
+	final func() (final, ok bool)
+}
+
+// Final reports whether the Policy would return Stop should this attempt
+// fail, based on Number and Elapsed. It is only computed when called.
+//
+// The prediction is only possible if the Policy passed to Run is composed
+// entirely of Policies of this package whose waits are deterministic and do
+// not depend on the error or on earlier attempts. If the Policy uses a Switch,
+// Phases, DecorrelatedJitter, any random Policy such as Randomize, or any
+// custom Policy, ok is false. Predicting never draws from a Rand or alters
+// any session of the Policy.
+func (a Attempt) Final() (final, ok bool) {
+	if a.final == nil {
+		return false, false
+	}
+	return a.final()
+}
+
+type attemptKey struct{}
+
+// predictable reports whether the waits of p depend only on attempts and
+// total, so that p can be called to predict Stop without side-effects.
+func predictable(p Policy) bool {
+	switch p := p.(type) {
+	case Immediate, Constant, Linear, Exponential,
+		Fibonacci, Polynomial, Logarithmic:
+		return true
+	case Schedule:
+		return p.Then == nil || predictable(p.Then)
+	case LimitAttempts:
+		return predictable(p.Policy)
+	case LimitTotal:
+		return predictable(p.Policy)
+	case LimitBudget:
+		return predictable(p.Policy)
+	case LimitDeadline:
+		return predictable(p.Policy)
+	case Max:
+		return predictable(p.Policy)
+	case Min:
+		return predictable(p.Policy)
+	case Offset:
+		return predictable(p.Policy)
+	case Scale:
+		return predictable(p.Policy)
+	case Window:
+		return predictable(p.Policy)
+	case Sum:
+		return allPredictable(p)
+	case LargestOf:
+		return allPredictable(p)
+	case SmallestOf:
+		return allPredictable(p)
+	}
+	return false
+}
+
+func allPredictable(ps []Policy) bool {
+	for _, p := range ps {
+		if !predictable(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// AttemptFromContext returns the Attempt added to ctx by Run, if any.
+func AttemptFromContext(ctx context.Context) (Attempt, bool) {
+	a, ok := ctx.Value(attemptKey{}).(Attempt)
+	return a, ok
+}
+
+// timeoutContext is a context.Context whose deadline is measured by a Clock,
+// rather than by the time package like context.WithTimeout.
+type timeoutContext struct {
+	context.Context
+	deadline time.Time
+
+	mu   sync.Mutex
+	done chan struct{}
+	err  error
+}
+
+// withTimeout returns a copy of ctx that is canceled once d has elapsed on
+// clock, at which point its Err, and that of any context derived from it,
+// returns context.DeadlineExceeded.
+func withTimeout(ctx context.Context, clock Clock,
+	d time.Duration) (context.Context, context.CancelFunc) {
+
+	if _, ok := clock.(systemClock); ok {
+		return context.WithTimeout(ctx, d)
+	}
+
+	// The embedded context provides Value and Cause, while Done and Err
+	// are those of tctx, so that contexts derived from tctx propagate
+	// its Err rather than context.Canceled.
+	cctx, cancel := context.WithCancelCause(ctx)
+	tctx := &timeoutContext{Context: cctx,
+		deadline: clock.Now().Add(d), done: make(chan struct{})}
+	tmr := clock.NewTimer(d)
+	go func() {
+		defer tmr.Stop()
+		select {
+		case <-tmr.GetC():
+			tctx.cancel(context.DeadlineExceeded)
+			cancel(context.DeadlineExceeded)
+		case <-cctx.Done():
+			tctx.cancel(cctx.Err())
+		}
+	}()
+	return tctx, func() {
+		tctx.cancel(context.Canceled)
+		cancel(context.Canceled)
+	}
+}
+
+// cancel closes Done and then sets Err to err, unless c is already done.
+func (c *timeoutContext) cancel(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	close(c.done)
+	c.err = err
+}
+
+func (c *timeoutContext) Deadline() (time.Time, bool) {
+	if d, ok := c.Context.Deadline(); ok && d.Before(c.deadline) {
+		return d, true
+	}
+	return c.deadline, true
+}
+
+func (c *timeoutContext) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *timeoutContext) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// contextDone returns an error wrapping err, ctx.Err() and context.Cause(ctx),
+// omitting any that are nil or redundant. If err is nil and ctx has no
+// distinct cause, ctx.Err() is returned directly.
+func contextDone(ctx context.Context, err error) error {
+	e := contextDoneError{err: err, ctxErr: ctx.Err()}
+	if cause := context.Cause(ctx); cause != e.ctxErr {
+		e.cause = cause
+	}
+	if e.err == nil && e.cause == nil {
+		return e.ctxErr
+	}
+	return e
+}
+
+// contextDoneError is the latest op error combined with the reason that ctx
+// is done.
+type contextDoneError struct {
+	err    error
+	ctxErr error
+	cause  error
+}
+
+func (e contextDoneError) Error() string {
+	var msg string
+	for _, err := range e.Unwrap() {
+		if len(msg) > 0 {
+			msg += ": "
+		}
+		msg += err.Error()
+	}
+	return msg
+}
+
+func (e contextDoneError) Unwrap() []error {
+	errs := make([]error, 0, 3)
+	for _, err := range []error{e.err, e.ctxErr, e.cause} {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
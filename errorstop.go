@@ -20,14 +20,65 @@
 
 package retry
 
+import "errors"
+
 // ErrorStop wraps err such that when returned from an op or filter, it will
 // cause Run to stop immediately and return err.
+//
+// The ErrorStop may be anywhere in the chain of the returned error, such as
+// when wrapped again using fmt.Errorf with %w.
 func ErrorStop(err error) error {
 	return errorStop{err}
 }
 
+// IsStop reports whether any error in the chain of err was wrapped by
+// ErrorStop.
+func IsStop(err error) bool {
+	var stop errorStop
+	return errors.As(err, &stop)
+}
+
 type errorStop struct{ err error }
 
 func (e errorStop) Error() string {
 	return e.err.Error()
 }
+
+func (e errorStop) Unwrap() error {
+	return e.err
+}
+
+// unwrapStop returns the original error if err is the direct result of
+// ErrorStop. Otherwise err is returned.
+func unwrapStop(err error) error {
+	if stop, ok := err.(errorStop); ok {
+		return stop.err
+	}
+	return err
+}
+
+// ErrorIgnore wraps err such that when returned from an op or filter, it will
+// cause Run to stop immediately and return nil, as if op succeeded.
+//
+// Like ErrorStop, the ErrorIgnore may be anywhere in the chain of the
+// returned error.
+func ErrorIgnore(err error) error {
+	return errorIgnore{err}
+}
+
+type errorIgnore struct{ err error }
+
+func (e errorIgnore) Error() string {
+	return e.err.Error()
+}
+
+func (e errorIgnore) Unwrap() error {
+	return e.err
+}
+
+// isIgnore reports whether any error in the chain of err was wrapped by
+// ErrorIgnore.
+func isIgnore(err error) bool {
+	var ignore errorIgnore
+	return errors.As(err, &ignore)
+}
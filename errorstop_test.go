@@ -21,6 +21,7 @@
 package retry
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 
@@ -28,6 +29,21 @@ import (
 )
 
 func TestErrorStop(t *testing.T) {
+	assert := assert.New(t)
 	err := fmt.Errorf("test")
-	assert.EqualError(t, ErrorStop(err), err.Error())
+	assert.EqualError(ErrorStop(err), err.Error())
+	assert.True(errors.Is(ErrorStop(err), err))
+	assert.True(IsStop(ErrorStop(err)))
+	assert.True(IsStop(fmt.Errorf("wrapped: %w", ErrorStop(err))))
+	assert.False(IsStop(err))
+	assert.False(IsStop(nil))
+}
+
+func TestErrorIgnore(t *testing.T) {
+	assert := assert.New(t)
+	err := fmt.Errorf("test")
+	assert.EqualError(ErrorIgnore(err), err.Error())
+	assert.True(errors.Is(ErrorIgnore(err), err))
+	assert.True(isIgnore(fmt.Errorf("wrapped: %w", ErrorIgnore(err))))
+	assert.False(isIgnore(err))
 }
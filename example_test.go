@@ -69,11 +69,41 @@ func ExampleRun() {
 
 	err := retry.Run(ctx, policy, filter, notify, func() error {
 		// If your op requires a context.Context you should create a
-		// closure around it. If tryWork returns context.Canceled or
-		// context.DeadlinExceeded Run will return immediately.
+		// closure around it, or use RunContext. If tryWork returns
+		// context.Canceled or context.DeadlinExceeded Run will return
+		// immediately.
 		return workToRetry(ctx)
 	})
 	if err != nil {
 		return
 	}
 }
+
+func ExampleRunValue() {
+	policy := retry.LimitAttempts{5, retry.Exponential{time.Second, 2}}
+
+	var ctx = context.TODO()
+
+	// The value from the successful attempt is returned directly, there is
+	// no need to capture it in a closure.
+	n, err := retry.RunValue(ctx, policy, nil, nil, func() (int, error) {
+		return 42, workToRetry(ctx)
+	})
+	if err != nil {
+		return
+	}
+	fmt.Println(n)
+	// Output: 42
+}
+
+func ExampleRunContext() {
+	policy := retry.LimitAttempts{5, retry.Exponential{time.Second, 2}}
+
+	// Each attempt gets its own ctx with a 10 second deadline, so a single
+	// hung attempt cannot use up the entire budget of the outer ctx.
+	err := retry.RunContext(context.TODO(), policy, nil, nil,
+		workToRetry, retry.WithAttemptTimeout(10*time.Second))
+	if err != nil {
+		return
+	}
+}
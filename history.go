@@ -0,0 +1,116 @@
+// Copyright 2019 Adam S Levy
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package retry
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AttemptError is the error returned by a single attempt of op.
+type AttemptError struct {
+	// Attempt is the 1-based number of the attempt.
+	Attempt uint
+
+	// Start is when the attempt started.
+	Start time.Time
+
+	// Duration is how long the attempt took, including filter.
+	Duration time.Duration
+
+	// Err is the filtered error returned by the attempt. If it was
+	// wrapped by ErrorStop, it is unwrapped.
+	Err error
+}
+
+func (e AttemptError) Error() string {
+	return fmt.Sprintf("attempt %v: %v", e.Attempt, e.Err)
+}
+
+func (e AttemptError) Unwrap() error {
+	return e.Err
+}
+
+// HistoryError is returned by Run when the WithErrorHistory Option is used
+// and op does not succeed. It holds the error of every attempt in order.
+//
+// A HistoryError unwraps to all of its Attempts, so errors.Is and errors.As
+// match an error returned by any attempt, not just the latest.
+type HistoryError struct {
+	Attempts []AttemptError
+
+	// Total is the time elapsed between the start of Run and its return.
+	Total time.Duration
+}
+
+// Error returns a summary of all attempts such as
+//
+//	failed after 5 attempts over 2m10s: 1: auth expired; 2-5: timeout
+//
+// where consecutive attempts with the same error message are grouped.
+func (e *HistoryError) Error() string {
+	var b strings.Builder
+	plural := "s"
+	if len(e.Attempts) == 1 {
+		plural = ""
+	}
+	fmt.Fprintf(&b, "failed after %v attempt%v over %v",
+		len(e.Attempts), plural, e.Total)
+	for i := 0; i < len(e.Attempts); {
+		first := e.Attempts[i]
+		msg := first.Err.Error()
+		j := i + 1
+		for j < len(e.Attempts) && e.Attempts[j].Err.Error() == msg {
+			j++
+		}
+		sep := "; "
+		if i == 0 {
+			sep = ": "
+		}
+		if last := e.Attempts[j-1]; j-i > 1 {
+			fmt.Fprintf(&b, "%v%v-%v: %v",
+				sep, first.Attempt, last.Attempt, msg)
+		} else {
+			fmt.Fprintf(&b, "%v%v: %v", sep, first.Attempt, msg)
+		}
+		i = j
+	}
+	return b.String()
+}
+
+// Unwrap returns the AttemptError of every attempt.
+func (e *HistoryError) Unwrap() []error {
+	errs := make([]error, len(e.Attempts))
+	for i, a := range e.Attempts {
+		errs[i] = a
+	}
+	return errs
+}
+
+// Last returns the error of the latest attempt, which is the error Run would
+// have returned without WithErrorHistory, or nil if there are no attempts.
+func (e *HistoryError) Last() error {
+	if len(e.Attempts) == 0 {
+		return nil
+	}
+	return e.Attempts[len(e.Attempts)-1].Err
+}
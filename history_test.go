@@ -0,0 +1,95 @@
+// Copyright 2019 Adam S Levy
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errTestAuth = errors.New("auth expired")
+
+func TestHistoryError(t *testing.T) {
+	t.Run("Run", func(t *testing.T) {
+		assert := assert.New(t)
+		clock := newMockClock()
+		errs := []error{
+			fmt.Errorf("login: %w", errTestAuth),
+			testStatusError(500),
+			fmt.Errorf("timeout"),
+			fmt.Errorf("timeout"),
+			fmt.Errorf("timeout"),
+		}
+		var i int
+		err := Run(nil, LimitAttempts{5, Constant(30 * time.Second)},
+			nil, nil, func() error {
+				defer func() { i++ }()
+				clock.now = clock.now.Add(time.Second)
+				return errs[i]
+			}, WithClock(clock), WithErrorHistory())
+
+		var history *HistoryError
+		if !assert.True(errors.As(err, &history)) {
+			return
+		}
+		assert.EqualError(err, "failed after 5 attempts over 2m5s: "+
+			"1: login: auth expired; 2: status 500; 3-5: timeout")
+		assert.True(errors.Is(err, errTestAuth))
+		var status testStatusError
+		assert.True(errors.As(err, &status))
+		assert.Equal(testStatusError(500), status)
+		assert.EqualError(history.Last(), "timeout")
+
+		assert.Len(history.Attempts, 5)
+		for i, a := range history.Attempts {
+			assert.Equal(uint(i+1), a.Attempt)
+			assert.Equal(time.Unix(0, 0).Add(
+				time.Duration(i)*31*time.Second), a.Start)
+			assert.Equal(time.Second, a.Duration)
+		}
+	})
+	t.Run("ErrorStop", func(t *testing.T) {
+		assert := assert.New(t)
+		err := Run(nil, Immediate{}, nil, nil, func() error {
+			return ErrorStop(errTestAuth)
+		}, WithClock(newMockClock()), WithErrorHistory())
+		assert.EqualError(err,
+			"failed after 1 attempt over 0s: 1: auth expired")
+		var history *HistoryError
+		assert.True(errors.As(err, &history))
+		assert.Equal(errTestAuth, history.Last())
+	})
+	t.Run("success", func(t *testing.T) {
+		err := Run(nil, Immediate{}, nil, nil, testOp(3, nil),
+			WithClock(newMockClock()), WithErrorHistory())
+		assert.NoError(t, err)
+	})
+	t.Run("empty", func(t *testing.T) {
+		history := &HistoryError{}
+		assert.Nil(t, history.Last())
+		assert.EqualError(t, history,
+			"failed after 0 attempts over 0s")
+	})
+}
@@ -0,0 +1,233 @@
+// Copyright 2019 Adam S Levy
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package retry
+
+import (
+	"math"
+	"time"
+
+	"github.com/JohnCGriffin/overflow"
+)
+
+// FullJitter wraps a Policy such that its wait time is randomly selected from
+// the range [0, wait].
+//
+// The wait time is drawn uniformly using the global source of math/rand. Use
+// WithRand to draw it from another Rand or Distribution.
+type FullJitter struct {
+	Policy
+}
+
+// Wait returns a wait time randomly selected from the range [0, wait], where
+// wait is the return value of f.Policy.Wait(attempts, total).
+//
+// If wait is 0 or Stop, it is returned directly.
+func (f FullJitter) Wait(attempts uint, total time.Duration) time.Duration {
+	return f.WaitErr(nil, attempts, total)
+}
+
+// WaitErr is like Wait but passes err to f.Policy if it is an
+// ErrorPolicy. If err was wrapped by RetryAfter, its wait is not randomized.
+func (f FullJitter) WaitErr(err error,
+	attempts uint, total time.Duration) time.Duration {
+
+	wait, _ := f.waitRand(nil, nil, err, attempts, total)
+	return wait
+}
+
+func (f FullJitter) waitExtended(err error,
+	attempts uint, total time.Duration) (time.Duration, bool) {
+
+	return f.waitRand(nil, nil, err, attempts, total)
+}
+
+// WithRand returns a Policy like f that draws its waits from dist using a Rand
+// returned by newRand. See Rand.
+func (f FullJitter) WithRand(newRand func() Rand, dist Distribution) Policy {
+	return randPolicy{policy: f, newRand: newRand, dist: dist}
+}
+
+func (f FullJitter) waitRand(r Rand, dist Distribution, err error,
+	attempts uint, total time.Duration) (time.Duration, bool) {
+
+	wait, extended := waitExtended(f.Policy, err, attempts, total)
+	if _, ok := RetryAfterDuration(err); ok || wait <= 0 {
+		return wait, extended
+	}
+	return randDuration(r, dist, 0, wait), extended
+}
+
+// NewSession returns a FullJitter wrapping a new session of f.Policy.
+func (f FullJitter) NewSession() Policy {
+	f.Policy = NewSession(f.Policy)
+	return f
+}
+
+// EqualJitter wraps a Policy such that half of its wait time is kept and the
+// other half is randomly selected, so the wait time is within the range
+// [wait/2, wait].
+//
+// The wait time is drawn uniformly using the global source of math/rand. Use
+// WithRand to draw it from another Rand or Distribution.
+type EqualJitter struct {
+	Policy
+}
+
+// Wait returns wait/2 plus a duration randomly selected from the range [0,
+// wait/2], where wait is the return value of e.Policy.Wait(attempts, total).
+//
+// If wait is 0 or Stop, it is returned directly.
+func (e EqualJitter) Wait(attempts uint, total time.Duration) time.Duration {
+	return e.WaitErr(nil, attempts, total)
+}
+
+// WaitErr is like Wait but passes err to e.Policy if it is an
+// ErrorPolicy. If err was wrapped by RetryAfter, its wait is not randomized.
+func (e EqualJitter) WaitErr(err error,
+	attempts uint, total time.Duration) time.Duration {
+
+	wait, _ := e.waitRand(nil, nil, err, attempts, total)
+	return wait
+}
+
+func (e EqualJitter) waitExtended(err error,
+	attempts uint, total time.Duration) (time.Duration, bool) {
+
+	return e.waitRand(nil, nil, err, attempts, total)
+}
+
+// WithRand returns a Policy like e that draws its waits from dist using a Rand
+// returned by newRand. See Rand.
+func (e EqualJitter) WithRand(newRand func() Rand, dist Distribution) Policy {
+	return randPolicy{policy: e, newRand: newRand, dist: dist}
+}
+
+func (e EqualJitter) waitRand(r Rand, dist Distribution, err error,
+	attempts uint, total time.Duration) (time.Duration, bool) {
+
+	wait, extended := waitExtended(e.Policy, err, attempts, total)
+	if _, ok := RetryAfterDuration(err); ok || wait <= 0 {
+		return wait, extended
+	}
+	half := wait / 2
+	return half + randDuration(r, dist, 0, wait-half), extended
+}
+
+// NewSession returns an EqualJitter wrapping a new session of e.Policy.
+func (e EqualJitter) NewSession() Policy {
+	e.Policy = NewSession(e.Policy)
+	return e
+}
+
+// DecorrelatedJitter is a Policy that randomly selects each wait time from
+// the range [Base, 3 * previous wait], capped to Cap. The first previous wait
+// is Base.
+//
+// Because each wait depends on the previous one, DecorrelatedJitter keeps
+// separate state for every call to Run, so it remains safe to share across
+// concurrent calls to Run.
+//
+// Base must be greater than 0 in order for the wait time to increase. If Cap
+// is 0, the wait time is only capped to the largest value that does not
+// overflow.
+//
+// Each wait time is drawn uniformly using the global source of math/rand. Use
+// WithRand to draw it from another Rand or Distribution.
+type DecorrelatedJitter struct {
+	Base time.Duration
+	Cap  time.Duration
+}
+
+// Wait returns the wait time of the first attempt of a new call to Run, a
+// random value from the range [d.Base, 3 * d.Base], capped to d.Cap.
+//
+// When used with Run, the state of the previous wait is tracked for each call
+// to Run.
+func (d DecorrelatedJitter) Wait(attempts uint, total time.Duration) time.Duration {
+	return d.next(nil, nil, d.Base)
+}
+
+// WithRand returns a Policy like d that draws its waits from dist using a Rand
+// returned by newRand. See Rand.
+func (d DecorrelatedJitter) WithRand(newRand func() Rand,
+	dist Distribution) Policy {
+
+	return randPolicy{policy: d, newRand: newRand, dist: dist}
+}
+
+func (d DecorrelatedJitter) waitRand(r Rand, dist Distribution, err error,
+	attempts uint, total time.Duration) (time.Duration, bool) {
+
+	if wait, ok := RetryAfterDuration(err); ok {
+		return wait, false
+	}
+	return d.next(r, dist, d.Base), false
+}
+
+// NewSession returns a Policy that tracks the previous wait time for a single
+// call to Run.
+func (d DecorrelatedJitter) NewSession() Policy {
+	return &decorrelatedJitter{d, d.Base}
+}
+
+// next returns a wait time drawn from dist using r and the previous wait time
+// prev.
+func (d DecorrelatedJitter) next(r Rand, dist Distribution,
+	prev time.Duration) time.Duration {
+
+	limit := d.Cap
+	if limit <= 0 {
+		limit = math.MaxInt64
+	}
+	max, ok := overflow.Mul64(int64(prev), 3)
+	if !ok {
+		max = math.MaxInt64
+	}
+	if max < int64(d.Base) {
+		max = int64(d.Base)
+	}
+	wait := randDuration(r, dist, d.Base, time.Duration(max))
+	if wait > limit {
+		return limit
+	}
+	return wait
+}
+
+// decorrelatedJitter is the per Run session of DecorrelatedJitter.
+type decorrelatedJitter struct {
+	DecorrelatedJitter
+	prev time.Duration
+}
+
+func (d *decorrelatedJitter) Wait(attempts uint, total time.Duration) time.Duration {
+	wait, _ := d.waitRand(nil, nil, nil, attempts, total)
+	return wait
+}
+
+func (d *decorrelatedJitter) waitRand(r Rand, dist Distribution, err error,
+	attempts uint, total time.Duration) (time.Duration, bool) {
+
+	if wait, ok := RetryAfterDuration(err); ok {
+		return wait, false
+	}
+	d.prev = d.next(r, dist, d.prev)
+	return d.prev, false
+}
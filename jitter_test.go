@@ -0,0 +1,109 @@
+// Copyright 2019 Adam S Levy
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package retry
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJitter(t *testing.T) {
+	t.Run("FullJitter", func(t *testing.T) {
+		policy := FullJitter{Constant(time.Minute)}
+		for i := 0; i < 1000; i++ {
+			wait := policy.Wait(1, 0)
+			assert.True(t, 0 <= wait && wait <= time.Minute, wait)
+		}
+	})
+	t.Run("EqualJitter", func(t *testing.T) {
+		policy := EqualJitter{Constant(time.Minute)}
+		for i := 0; i < 1000; i++ {
+			wait := policy.Wait(1, 0)
+			assert.True(t, time.Minute/2 <= wait && wait <= time.Minute,
+				wait)
+		}
+	})
+	t.Run("overflow", func(t *testing.T) {
+		for _, policy := range []Policy{
+			FullJitter{Constant(math.MaxInt64)},
+			EqualJitter{Constant(math.MaxInt64)},
+			DecorrelatedJitter{math.MaxInt64, 0},
+		} {
+			assert.True(t, policy.Wait(1, 0) >= 0)
+		}
+	})
+	t.Run("stop", func(t *testing.T) {
+		for _, policy := range []Policy{
+			FullJitter{Constant(Stop)},
+			EqualJitter{Constant(Stop)},
+			FullJitter{Immediate{}},
+			EqualJitter{Immediate{}},
+		} {
+			wait := policy.Wait(1, 0)
+			assert.True(t, wait == Stop || wait == 0, wait)
+		}
+	})
+	t.Run("DecorrelatedJitter", func(t *testing.T) {
+		assert := assert.New(t)
+		policy := DecorrelatedJitter{time.Second, time.Minute}
+		for i := 0; i < 1000; i++ {
+			wait := policy.Wait(uint(i), 0)
+			assert.True(time.Second <= wait && wait <= 3*time.Second,
+				wait)
+		}
+
+		session := NewSession(policy)
+		prev := time.Second
+		for i := uint(1); i < 100; i++ {
+			wait := session.Wait(i, 0)
+			max := 3 * prev
+			if max > time.Minute {
+				max = time.Minute
+			}
+			assert.True(time.Second <= wait && wait <= max,
+				"attempt %v wait %v prev %v", i, wait, prev)
+			prev = wait
+		}
+	})
+	t.Run("DecorrelatedJitter/Run", func(t *testing.T) {
+		assert := assert.New(t)
+		policy := LimitAttempts{5, Max{time.Hour,
+			DecorrelatedJitter{time.Second, 0}}}
+
+		// Every call to Run must start from Base.
+		for i := 0; i < 10; i++ {
+			var waits []time.Duration
+			notify := func(_ error, _ uint, d time.Duration) {
+				waits = append(waits, d)
+			}
+			err := Run(nil, policy, nil, notify,
+				func() error { return fmt.Errorf("failed") },
+				WithClock(newMockClock()))
+			assert.EqualError(err, "failed")
+			assert.Len(waits, 4)
+			assert.True(waits[0] <= 3*time.Second, waits[0])
+		}
+	})
+}
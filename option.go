@@ -0,0 +1,144 @@
+// Copyright 2019 Adam S Levy
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package retry
+
+import "time"
+
+// Option configures optional behavior of Run and RunValue.
+type Option func(*config)
+
+type config struct {
+	clock          Clock
+	result         *Result
+	history        bool
+	attemptTimeout Policy
+	minWait        time.Duration
+
+	deadlineMode    DeadlineMode
+	deadlineReserve time.Duration
+}
+
+func newConfig(opts []Option) config {
+	c := config{clock: systemClock{}}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// WithClock tells Run to use c for all timing instead of the system clock.
+//
+// The total time passed to Policy.Wait and all waits are measured using c, as
+// is the current time of any LimitDeadline or Window within the Policy. If c
+// is nil, the system clock is used.
+func WithClock(c Clock) Option {
+	return func(cfg *config) {
+		if c != nil {
+			cfg.clock = c
+		}
+	}
+}
+
+// WithResult tells Run to store a Result describing why and after how many
+// attempts it returned in r. The Result is stored just before Run returns,
+// regardless of whether op succeeded.
+func WithResult(r *Result) Option {
+	return func(cfg *config) {
+		cfg.result = r
+	}
+}
+
+// WithErrorHistory tells Run to return a *HistoryError holding the error of
+// every attempt, instead of only the latest error, when op does not succeed.
+func WithErrorHistory() Option {
+	return func(cfg *config) {
+		cfg.history = true
+	}
+}
+
+// WithAttemptTimeout gives each attempt of op a deadline of d after it
+// starts. See RunContext.
+//
+// The deadline is only visible to an op that accepts a context.Context, so
+// this Option is only useful with RunContext and RunValueContext.
+func WithAttemptTimeout(d time.Duration) Option {
+	return WithAttemptTimeoutPolicy(Constant(d))
+}
+
+// WithAttemptTimeoutPolicy is like WithAttemptTimeout but the timeout of each
+// attempt is determined by p, so that later attempts may be given more or
+// less time.
+//
+// Before each attempt, p is passed the number of the upcoming attempt, the
+// total time elapsed and, if p is an ErrorPolicy, the error of the previous
+// attempt. A RetryAfter in that error does not replace the timeout. If p
+// returns 0 or Stop, the attempt has no timeout of its own.
+func WithAttemptTimeoutPolicy(p Policy) Option {
+	return func(cfg *config) {
+		cfg.attemptTimeout = p
+	}
+}
+
+// WithMinWait tells Run to wait at least d between attempts, even if the
+// Policy returns a shorter wait or 0. This prevents a Policy such as
+// Immediate from retrying in a tight loop. The raised wait is passed to
+// notify.
+func WithMinWait(d time.Duration) Option {
+	return func(cfg *config) {
+		cfg.minWait = d
+	}
+}
+
+// DeadlineMode controls what Run does when the next wait would not end before
+// the deadline of ctx. See WithDeadlineMode.
+type DeadlineMode int
+
+// The possible DeadlineModes.
+const (
+	// DeadlineWait starts the wait regardless of the deadline. Run
+	// returns when ctx.Done() is closed. This is the default.
+	DeadlineWait DeadlineMode = iota
+
+	// DeadlineStop returns immediately with ReasonDeadline instead of
+	// starting a wait that would not end in time for another attempt.
+	DeadlineStop
+
+	// DeadlineShorten shortens the wait so that one final attempt starts
+	// in time. If there is not enough time left for even that, Run
+	// returns immediately with ReasonDeadline.
+	DeadlineShorten
+)
+
+// WithDeadlineMode tells Run to compare each wait against ctx.Deadline()
+// according to mode.
+//
+// The reserve is the time estimated for an attempt of op. A wait is
+// considered to not end in time if the deadline is less than the wait plus
+// reserve away. With DeadlineShorten, the wait is shortened to end reserve
+// before the deadline. The shortened wait is passed to notify.
+//
+// If ctx has no deadline, this Option has no effect.
+func WithDeadlineMode(mode DeadlineMode, reserve time.Duration) Option {
+	return func(cfg *config) {
+		cfg.deadlineMode = mode
+		cfg.deadlineReserve = reserve
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2019 Adam S Levy
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package retry
+
+import "time"
+
+// Phases is a Policy that hands off between a sequence of Policies, for
+// example to retry quickly a few times, then back off exponentially, and
+// finally fall into a slow steady poll.
+//
+// Each Phase is used until it has handled its number of Attempts or its
+// Duration has elapsed, at which point the next Phase begins. The attempts
+// and total time passed to the Policy of each Phase are rebased to the start
+// of that Phase, so that for example an Exponential starts from its Initial
+// wait when its Phase begins.
+//
+// If the final Phase ends, Stop is returned, so give the final Phase no
+// limits to continue indefinitely.
+//
+// When used with Run, the start of each Phase is tracked for each call to
+// Run. Outside of Run, each call to Wait starts from the first Phase, which
+// is only exact when Phases are limited by Attempts alone.
+type Phases []Phase
+
+// Phase is a single Policy within Phases.
+//
+// Attempts is the number of failed attempts handled by the Phase and Duration
+// is the time that the Phase lasts. A Phase ends at whichever limit comes
+// first. A zero Attempts or Duration means no limit.
+type Phase struct {
+	Attempts uint
+	Duration time.Duration
+	Policy
+}
+
+// Wait returns the wait of the Policy of the current Phase. See Phases.
+func (p Phases) Wait(attempts uint, total time.Duration) time.Duration {
+	return p.WaitErr(nil, attempts, total)
+}
+
+// WaitErr is like Wait but passes err to the Policy of the current Phase if
+// it is an ErrorPolicy.
+func (p Phases) WaitErr(err error,
+	attempts uint, total time.Duration) time.Duration {
+
+	var s phasesSession
+	s.Phases = p
+	return s.WaitErr(err, attempts, total)
+}
+
+// NewSession returns a Policy that tracks the start of each Phase for a
+// single call to Run.
+func (p Phases) NewSession() Policy {
+	s := phasesSession{Phases: make(Phases, len(p))}
+	for i, phase := range p {
+		s.Phases[i] = Phase{phase.Attempts, phase.Duration,
+			NewSession(phase.Policy)}
+	}
+	return &s
+}
+
+// phasesSession is the per Run session of Phases.
+type phasesSession struct {
+	Phases
+
+	// i is the index of the current Phase, which started after
+	// startAttempts failed attempts at startTotal.
+	i             int
+	startAttempts uint
+	startTotal    time.Duration
+}
+
+func (s *phasesSession) Wait(attempts uint, total time.Duration) time.Duration {
+	return s.WaitErr(nil, attempts, total)
+}
+
+func (s *phasesSession) WaitErr(err error,
+	attempts uint, total time.Duration) time.Duration {
+
+	for ; s.i < len(s.Phases); s.i++ {
+		phase := s.Phases[s.i]
+		a := attempts - s.startAttempts
+		t := total - s.startTotal
+		switch {
+		case phase.Attempts > 0 && a > phase.Attempts:
+			s.startAttempts += phase.Attempts
+		case phase.Duration > 0 && t >= phase.Duration:
+			s.startAttempts = attempts - 1
+		default:
+			return WaitErr(phase.Policy, err, a, t)
+		}
+		s.startTotal = total
+	}
+	return Stop
+}
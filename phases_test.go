@@ -0,0 +1,57 @@
+// Copyright 2019 Adam S Levy
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package retry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPhases(t *testing.T) {
+	t.Run("session", func(t *testing.T) {
+		assert := assert.New(t)
+		policy := Phases{
+			{0, time.Minute, Constant(10 * time.Second)},
+			{2, 0, Exponential{time.Second, 2}},
+			{0, 0, LimitTotal{time.Minute, Constant(30 * time.Second)}},
+		}
+		session := NewSession(policy)
+		for i, test := range []struct {
+			Args policyArgs
+			Wait time.Duration
+		}{
+			{policyArgs{1, 0}, 10 * time.Second},
+			{policyArgs{2, 50 * time.Second}, 10 * time.Second},
+			{policyArgs{3, 65 * time.Second}, time.Second},
+			{policyArgs{4, 66 * time.Second}, 2 * time.Second},
+			{policyArgs{5, 70 * time.Second}, 30 * time.Second},
+			{policyArgs{6, 100 * time.Second}, 30 * time.Second},
+			{policyArgs{7, 130 * time.Second}, Stop},
+		} {
+			wait := session.Wait(test.Args.Attempts, test.Args.Total)
+			assert.Equalf(test.Wait, wait, "index %v", i)
+		}
+		// The original Policy is not affected by the session.
+		assert.Equal(10*time.Second, policy.Wait(2, 50*time.Second))
+	})
+}
@@ -22,7 +22,6 @@ package retry
 
 import (
 	"math"
-	"math/rand"
 	"time"
 
 	"github.com/JohnCGriffin/overflow"
@@ -48,10 +47,47 @@ type Policy interface {
 	// In order to ensure that a Policy is re-usable across concurrent
 	// calls to Run, Wait should not have any side-effects such as mutating
 	// any internal state of Policy. The one exception to this is the use
-	// of math/rand.Float64() the in Randomize Policy.
+	// of math/rand in the Randomize, FullJitter, EqualJitter and
+	// DecorrelatedJitter Policies. A Policy that needs state across
+	// attempts should implement SessionPolicy, which provides separate
+	// state for each call to Run.
 	Wait(attempts uint, total time.Duration) (wait time.Duration)
 }
 
+// ErrorPolicy is a Policy that also considers the error returned by the latest
+// attempt of op.
+//
+// If the Policy passed to Run is an ErrorPolicy, Run calls WaitErr with the
+// latest filtered op error instead of calling Wait. Otherwise the rules are
+// the same as for Policy.Wait.
+//
+// Wrapper Policies should pass err along to their wrapped Policy using the
+// WaitErr function. All wrappers in this package do this.
+type ErrorPolicy interface {
+	Policy
+	WaitErr(err error, attempts uint, total time.Duration) (wait time.Duration)
+}
+
+// WaitErr returns p.WaitErr(err, attempts, total) if p is an ErrorPolicy.
+// Otherwise p.Wait(attempts, total) is returned, except that if err was
+// wrapped by RetryAfter, its wait replaces any wait other than Stop. Thus a
+// Policy that stops still stops, even if every error carries a RetryAfter.
+func WaitErr(p Policy, err error,
+	attempts uint, total time.Duration) time.Duration {
+
+	if e, ok := p.(ErrorPolicy); ok {
+		return e.WaitErr(err, attempts, total)
+	}
+	wait := p.Wait(attempts, total)
+	if wait <= Stop {
+		return wait
+	}
+	if d, ok := RetryAfterDuration(err); ok {
+		return d
+	}
+	return wait
+}
+
 // Immediate is a Policy that always returns a zero wait time.
 type Immediate struct{}
 
@@ -92,19 +128,103 @@ type Exponential struct {
 	Multiplier float64
 }
 
-// Wait returns e.Initial * math.Pow(e.Multiplier, attempts) up to the number
-// of attempts that would cause overflow, at which point the largest value that
-// does not overflow is returned.
+// Wait returns e.Initial * math.Pow(e.Multiplier, attempts-1) up to the
+// largest value that does not overflow, which is math.MaxInt64.
+//
+// Wait takes constant time regardless of attempts. For up to
+// exponentialSteps attempts, the result is computed by repeated multiplication
+// so that it is identical to multiplying by e.Multiplier once per attempt.
 func (e Exponential) Wait(attempts uint, total time.Duration) time.Duration {
+	if attempts <= 1 || e.Initial == 0 {
+		return e.Initial
+	}
+	n := attempts - 1
 	wait := float64(e.Initial)
-	overflow := math.MaxInt64 / e.Multiplier
+	if n > exponentialSteps {
+		return floatDuration(wait * math.Pow(e.Multiplier, float64(n)))
+	}
+	for i := uint(0); i < n && wait != 0 && !math.IsInf(wait, 0); i++ {
+		wait *= e.Multiplier
+	}
+	return floatDuration(wait)
+}
+
+// exponentialSteps is the number of attempts up to which Exponential.Wait
+// multiplies step by step rather than calling math.Pow, whose rounding may
+// differ in the last bit.
+const exponentialSteps = 64
+
+// Fibonacci is a Policy that increases wait time following the Fibonacci
+// sequence, starting from Initial for the first two attempts, so it grows
+// faster than Linear but slower than Exponential with a Multiplier of 2.
+type Fibonacci struct {
+	Initial time.Duration
+}
+
+// Wait returns f.Initial * F(attempts), where F(1) = F(2) = 1 and F(n) =
+// F(n-1) + F(n-2), or math.MaxInt64 if any integer overflow occurs.
+func (f Fibonacci) Wait(attempts uint, total time.Duration) time.Duration {
+	var prev, fib int64 = 0, 1
 	for i := uint(1); i < attempts; i++ {
-		if wait == 0 || wait > overflow {
-			break
+		next, ok := overflow.Add64(prev, fib)
+		if !ok {
+			return math.MaxInt64
 		}
-		wait *= e.Multiplier
+		prev, fib = fib, next
+	}
+	if wait, ok := overflow.Mul64(int64(f.Initial), fib); ok {
+		return time.Duration(wait)
+	}
+	return math.MaxInt64
+}
+
+// Polynomial is a Policy that increases wait time polynomially starting from
+// Initial, such that the wait time is proportional to attempts raised to
+// Degree.
+//
+// A Degree of 1 is equivalent to Linear{Initial, Initial}. A Degree between 0
+// and 1 grows ever more slowly, such as 0.5 for a square root.
+type Polynomial struct {
+	Initial time.Duration
+	Degree  float64
+}
+
+// Wait returns p.Initial * math.Pow(attempts, p.Degree) up to the largest value
+// that does not overflow.
+func (p Polynomial) Wait(attempts uint, total time.Duration) time.Duration {
+	if attempts == 0 {
+		attempts = 1
 	}
-	return time.Duration(wait)
+	return floatDuration(float64(p.Initial) *
+		math.Pow(float64(attempts), p.Degree))
+}
+
+// Logarithmic is a Policy that increases wait time logarithmically starting
+// from Initial, so it levels off over many attempts.
+type Logarithmic struct {
+	Initial time.Duration
+}
+
+// Wait returns l.Initial * math.Log2(attempts + 1) up to the largest value
+// that does not overflow.
+func (l Logarithmic) Wait(attempts uint, total time.Duration) time.Duration {
+	if attempts == 0 {
+		attempts = 1
+	}
+	return floatDuration(float64(l.Initial) *
+		math.Log2(float64(attempts)+1))
+}
+
+// floatDuration converts f to a time.Duration, saturating at math.MaxInt64 or
+// math.MinInt64 rather than overflowing.
+func floatDuration(f float64) time.Duration {
+	if f >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	if f <= math.MinInt64 {
+		return math.MinInt64
+	}
+	return time.Duration(f)
 }
 
 // LimitAttempts wraps a Policy such that Run will return after Limit attempts.
@@ -116,14 +236,37 @@ type LimitAttempts struct {
 // Wait returns Stop if attempts >= l.Limit, otherwise the result of
 // l.Policy.Wait(attempts, total) is returned.
 func (l LimitAttempts) Wait(attempts uint, total time.Duration) time.Duration {
+	return l.WaitErr(nil, attempts, total)
+}
+
+// WaitErr is like Wait but passes err to l.Policy if it is an
+// ErrorPolicy.
+func (l LimitAttempts) WaitErr(err error,
+	attempts uint, total time.Duration) time.Duration {
+
+	wait, _ := l.waitExtended(err, attempts, total)
+	return wait
+}
+
+func (l LimitAttempts) waitExtended(err error,
+	attempts uint, total time.Duration) (time.Duration, bool) {
+
 	if attempts >= l.Limit {
-		return Stop
+		return Stop, false
 	}
-	return l.Policy.Wait(attempts, total)
+	return waitExtended(l.Policy, err, attempts, total)
+}
+
+// NewSession returns a LimitAttempts wrapping a new session of l.Policy.
+func (l LimitAttempts) NewSession() Policy {
+	return LimitAttempts{l.Limit, NewSession(l.Policy)}
 }
 
 // LimitTotal wraps a Policy such that Run will stop after total time meets or
 // exceeds Limit.
+//
+// If Policy wraps a Window whose wait is extended to an opening after Limit,
+// Stop is also returned, since no attempt could occur within Limit.
 type LimitTotal struct {
 	Limit time.Duration
 	Policy
@@ -132,10 +275,146 @@ type LimitTotal struct {
 // Wait returns Stop if total >= l.Limit, otherwise the result of
 // l.Policy.Wait(attempts, total) is returned.
 func (l LimitTotal) Wait(attempts uint, total time.Duration) time.Duration {
+	return l.WaitErr(nil, attempts, total)
+}
+
+// WaitErr is like Wait but passes err to l.Policy if it is an
+// ErrorPolicy.
+func (l LimitTotal) WaitErr(err error,
+	attempts uint, total time.Duration) time.Duration {
+
+	wait, _ := l.waitExtended(err, attempts, total)
+	return wait
+}
+
+func (l LimitTotal) waitExtended(err error,
+	attempts uint, total time.Duration) (time.Duration, bool) {
+
 	if total >= l.Limit {
-		return Stop
+		return Stop, false
+	}
+	wait, extended := waitExtended(l.Policy, err, attempts, total)
+	if extended && addDuration(total, wait) > l.Limit {
+		return Stop, false
+	}
+	return wait, extended
+}
+
+// NewSession returns a LimitTotal wrapping a new session of l.Policy.
+func (l LimitTotal) NewSession() Policy {
+	return LimitTotal{l.Limit, NewSession(l.Policy)}
+}
+
+// LimitBudget wraps a Policy such that Run never waits past Limit total time.
+//
+// Unlike LimitTotal, which only stops once total meets or exceeds Limit,
+// LimitBudget also considers the upcoming wait. Reserve is an estimate of how
+// long an attempt of op takes, which is also kept within the budget. If
+// Truncate is true, a wait that would exceed the budget is shortened so that
+// one final attempt fits, otherwise Stop is returned. A wait extended by a
+// wrapped Window is never shortened, since the attempt would then occur
+// outside of its Ranges.
+type LimitBudget struct {
+	Limit    time.Duration
+	Reserve  time.Duration
+	Truncate bool
+	Policy
+}
+
+// Wait returns Stop if total+wait+l.Reserve would exceed l.Limit, where wait
+// is the result of l.Policy.Wait(attempts, total). If l.Truncate is true, the
+// wait is instead shortened to l.Limit-l.Reserve-total, unless that is not
+// greater than 0.
+func (l LimitBudget) Wait(attempts uint, total time.Duration) time.Duration {
+	return l.WaitErr(nil, attempts, total)
+}
+
+// WaitErr is like Wait but passes err to l.Policy if it is an
+// ErrorPolicy.
+func (l LimitBudget) WaitErr(err error,
+	attempts uint, total time.Duration) time.Duration {
+
+	wait, _ := l.waitExtended(err, attempts, total)
+	return wait
+}
+
+func (l LimitBudget) waitExtended(err error,
+	attempts uint, total time.Duration) (time.Duration, bool) {
+
+	left := l.Limit - l.Reserve - total
+	if left <= 0 {
+		return Stop, false
+	}
+	wait, extended := waitExtended(l.Policy, err, attempts, total)
+	if wait > left {
+		if l.Truncate && !extended {
+			return left, false
+		}
+		return Stop, false
+	}
+	return wait, extended
+}
+
+// NewSession returns a LimitBudget wrapping a new session of l.Policy.
+func (l LimitBudget) NewSession() Policy {
+	return LimitBudget{l.Limit, l.Reserve, l.Truncate, NewSession(l.Policy)}
+}
+
+// LimitDeadline wraps a Policy such that Run will stop once the absolute time
+// Deadline has passed, regardless of when Run was called.
+//
+// A wait that would end after Deadline is shortened to end at Deadline,
+// unless it was extended by a wrapped Window, in which case Stop is returned.
+//
+// Run obtains the current time from its Clock, so that a Clock passed with
+// WithClock applies. Outside of Run, the system clock is used.
+type LimitDeadline struct {
+	Deadline time.Time
+	Policy
+}
+
+// Wait returns Stop if l.Deadline has passed, otherwise the result of
+// l.Policy.Wait(attempts, total) is returned, shortened if needed so that the
+// wait ends no later than l.Deadline.
+func (l LimitDeadline) Wait(attempts uint, total time.Duration) time.Duration {
+	return l.WaitErr(nil, attempts, total)
+}
+
+// WaitErr is like Wait but passes err to l.Policy if it is an
+// ErrorPolicy.
+func (l LimitDeadline) WaitErr(err error,
+	attempts uint, total time.Duration) time.Duration {
+
+	wait, _ := l.waitExtended(err, attempts, total)
+	return wait
+}
+
+func (l LimitDeadline) waitExtended(err error,
+	attempts uint, total time.Duration) (time.Duration, bool) {
+
+	return l.waitClock(systemClock{}, err, attempts, total)
+}
+
+func (l LimitDeadline) waitClock(clock Clock, err error,
+	attempts uint, total time.Duration) (time.Duration, bool) {
+
+	left := l.Deadline.Sub(clock.Now())
+	if left <= 0 {
+		return Stop, false
+	}
+	wait, extended := waitExtended(l.Policy, err, attempts, total)
+	if wait > left {
+		if extended {
+			return Stop, false
+		}
+		return left, false
 	}
-	return l.Policy.Wait(attempts, total)
+	return wait, extended
+}
+
+// NewSession returns a LimitDeadline wrapping a new session of l.Policy.
+func (l LimitDeadline) NewSession() Policy {
+	return LimitDeadline{l.Deadline, NewSession(l.Policy)}
 }
 
 // Max wraps a Policy such that wait time is capped to Cap.
@@ -147,15 +426,38 @@ type Max struct {
 // Wait returns the minimum between m.Max and the result of
 // m.Policy.Wait(attempts, total).
 func (m Max) Wait(attempts uint, total time.Duration) time.Duration {
-	wait := m.Policy.Wait(attempts, total)
+	return m.WaitErr(nil, attempts, total)
+}
+
+// WaitErr is like Wait but passes err to m.Policy if it is an
+// ErrorPolicy.
+func (m Max) WaitErr(err error,
+	attempts uint, total time.Duration) time.Duration {
+
+	wait, _ := m.waitExtended(err, attempts, total)
+	return wait
+}
+
+func (m Max) waitExtended(err error,
+	attempts uint, total time.Duration) (time.Duration, bool) {
+
+	wait, extended := waitExtended(m.Policy, err, attempts, total)
 	if wait > m.Cap {
-		return m.Cap
+		return m.Cap, false
 	}
-	return wait
+	return wait, extended
+}
+
+// NewSession returns a Max wrapping a new session of m.Policy.
+func (m Max) NewSession() Policy {
+	return Max{m.Cap, NewSession(m.Policy)}
 }
 
 // Randomize wraps a Policy such that its wait time is randomly selected from
 // the range [wait * (1 - Factor), wait * (1 + Factor)].
+//
+// The wait time is drawn uniformly using the global source of math/rand. Use
+// WithRand to draw it from another Rand or Distribution.
 type Randomize struct {
 	Factor float64
 	Policy
@@ -170,9 +472,36 @@ type Randomize struct {
 //
 // If wait is 0 or Stop, it is returned directly.
 func (r Randomize) Wait(attempts uint, total time.Duration) time.Duration {
-	wait := r.Policy.Wait(attempts, total)
-	if wait <= 0 {
-		return wait
+	return r.WaitErr(nil, attempts, total)
+}
+
+// WaitErr is like Wait but passes err to r.Policy if it is an
+// ErrorPolicy. If err was wrapped by RetryAfter, its wait is not randomized.
+func (r Randomize) WaitErr(err error,
+	attempts uint, total time.Duration) time.Duration {
+
+	wait, _ := r.waitRand(nil, nil, err, attempts, total)
+	return wait
+}
+
+func (r Randomize) waitExtended(err error,
+	attempts uint, total time.Duration) (time.Duration, bool) {
+
+	return r.waitRand(nil, nil, err, attempts, total)
+}
+
+// WithRand returns a Policy like r that draws its waits from dist using a Rand
+// returned by newRand. See Rand.
+func (r Randomize) WithRand(newRand func() Rand, dist Distribution) Policy {
+	return randPolicy{policy: r, newRand: newRand, dist: dist}
+}
+
+func (r Randomize) waitRand(rnd Rand, dist Distribution, err error,
+	attempts uint, total time.Duration) (time.Duration, bool) {
+
+	wait, extended := waitExtended(r.Policy, err, attempts, total)
+	if _, ok := RetryAfterDuration(err); ok || wait <= 0 {
+		return wait, extended
 	}
 
 	min := float64(wait) * (1 - r.Factor)
@@ -185,5 +514,17 @@ func (r Randomize) Wait(attempts uint, total time.Duration) time.Duration {
 	// The formula below uses a +1 to account for truncation of float64
 	// into int64. If the min is 1 and the max is 3 then we want a 33%
 	// chance for selecting either 1, 2 or 3.
-	return time.Duration(min + (rand.Float64() * (max - min + 1)))
+	f := sample(rnd, dist)
+	wait = floatDuration(min + (f * (max - min + 1)))
+	if float64(wait) > max {
+		// The sample was 1.
+		return floatDuration(max), extended
+	}
+	return wait, extended
+}
+
+// NewSession returns a Randomize wrapping a new session of r.Policy.
+func (r Randomize) NewSession() Policy {
+	r.Policy = NewSession(r.Policy)
+	return r
 }
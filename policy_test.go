@@ -21,6 +21,7 @@
 package retry
 
 import (
+	"fmt"
 	"math"
 	"testing"
 	"time"
@@ -50,6 +51,20 @@ func TestPolicy(t *testing.T) {
 		wait := policy.Wait(0, 0)
 		assert.Equal(t, Stop, wait)
 	})
+	t.Run("LimitDeadline/WithClock", func(t *testing.T) {
+		// The mock clock starts at the Unix epoch, so the Deadline has
+		// long passed on the system clock.
+		var waits []time.Duration
+		notify := func(_ error, _ uint, d time.Duration) {
+			waits = append(waits, d)
+		}
+		Run(nil, LimitDeadline{time.Unix(0, 0).Add(90 * time.Second),
+			Constant(time.Minute)}, nil, notify, func() error {
+			return errTestReset
+		}, WithClock(newMockClock()))
+		assert.Equal(t, []time.Duration{time.Minute, 30 * time.Second},
+			waits)
+	})
 }
 func testPolicy(t *testing.T, test policyTest) {
 	assert := assert.New(t)
@@ -91,13 +106,150 @@ var policyTests = []policyTest{{
 }, {
 	Name:   "Exponential/overflow",
 	Policy: Exponential{time.Minute, math.MaxInt64},
-	Args:   []policyArgs{{1, 0}, {2, 0}},
-	Wait:   []time.Duration{time.Minute, time.Minute},
+	Args:   []policyArgs{{1, 0}, {2, 0}, {3, 0}},
+	Wait:   []time.Duration{time.Minute, math.MaxInt64, math.MaxInt64},
+}, {
+	Name:   "Exponential/saturate",
+	Policy: Exponential{time.Second, 2},
+	Args:   []policyArgs{{34, 0}, {35, 0}, {65, 0}, {66, 0}, {math.MaxUint32, 0}},
+	Wait: []time.Duration{time.Second << 33, math.MaxInt64, math.MaxInt64,
+		math.MaxInt64, math.MaxInt64},
+}, {
+	Name:   "Exponential/large attempts",
+	Policy: Exponential{time.Second, 1.00001},
+	Args:   []policyArgs{{1, 0}, {1e6 + 1, 0}, {1e7 + 1, 0}},
+	Wait: []time.Duration{time.Second,
+		time.Duration(float64(time.Second) * math.Pow(1.00001, 1e6)),
+		math.MaxInt64},
+}, {
+	Name:   "Exponential/decay",
+	Policy: Exponential{time.Minute, .5},
+	Args:   []policyArgs{{2, 0}, {3, 0}, {1000, 0}},
+	Wait:   []time.Duration{30 * time.Second, 15 * time.Second, 0},
+}, {
+	Name:   "Fibonacci",
+	Policy: Fibonacci{time.Second},
+	Args:   []policyArgs{{0, 0}, {1, 0}, {2, 0}, {3, 0}, {4, 0}, {5, 0}, {10, 0}},
+	Wait: []time.Duration{time.Second, time.Second, time.Second,
+		2 * time.Second, 3 * time.Second, 5 * time.Second, 55 * time.Second},
+}, {
+	Name:   "Fibonacci/overflow",
+	Policy: Fibonacci{time.Second},
+	Args:   []policyArgs{{60, 0}, {100, 0}, {math.MaxUint32, 0}},
+	Wait:   []time.Duration{math.MaxInt64, math.MaxInt64, math.MaxInt64},
+}, {
+	Name:   "Polynomial",
+	Policy: Polynomial{time.Second, 2},
+	Args:   []policyArgs{{0, 0}, {1, 0}, {2, 0}, {3, 0}, {10, 0}},
+	Wait: []time.Duration{time.Second, time.Second, 4 * time.Second,
+		9 * time.Second, 100 * time.Second},
+}, {
+	Name:   "Polynomial/sqrt",
+	Policy: Polynomial{time.Second, .5},
+	Args:   []policyArgs{{4, 0}, {100, 0}},
+	Wait:   []time.Duration{2 * time.Second, 10 * time.Second},
+}, {
+	Name:   "Polynomial/overflow",
+	Policy: Polynomial{time.Hour, 10},
+	Args:   []policyArgs{{1000, 0}},
+	Wait:   []time.Duration{math.MaxInt64},
+}, {
+	Name:   "Logarithmic",
+	Policy: Logarithmic{time.Minute},
+	Args:   []policyArgs{{0, 0}, {1, 0}, {3, 0}, {7, 0}, {1023, 0}},
+	Wait: []time.Duration{time.Minute, time.Minute, 2 * time.Minute,
+		3 * time.Minute, 10 * time.Minute},
+}, {
+	Name:   "Logarithmic/overflow",
+	Policy: Logarithmic{math.MaxInt64},
+	Args:   []policyArgs{{3, 0}},
+	Wait:   []time.Duration{math.MaxInt64},
 }, {
 	Name:   "LimitTotal",
 	Policy: LimitTotal{3 * time.Minute, Constant(time.Minute)},
 	Args:   []policyArgs{{1, 0}, {2, 30 * time.Second}, {3, time.Hour}},
 	Wait:   []time.Duration{time.Minute, time.Minute, Stop},
+}, {
+	Name:   "LimitBudget",
+	Policy: LimitBudget{20 * time.Minute, time.Minute, false, Constant(10 * time.Minute)},
+	Args: []policyArgs{{1, 0}, {2, 9 * time.Minute},
+		{3, 10 * time.Minute}, {4, 19 * time.Minute}},
+	Wait: []time.Duration{10 * time.Minute, 10 * time.Minute,
+		Stop, Stop},
+}, {
+	Name:   "LimitBudget/Truncate",
+	Policy: LimitBudget{20 * time.Minute, time.Minute, true, Constant(10 * time.Minute)},
+	Args: []policyArgs{{1, 0}, {2, 9 * time.Minute},
+		{3, 10 * time.Minute}, {4, 19 * time.Minute}},
+	Wait: []time.Duration{10 * time.Minute, 10 * time.Minute,
+		9 * time.Minute, Stop},
+}, {
+	Name:   "LimitBudget/Stop",
+	Policy: LimitBudget{time.Hour, 0, true, LimitAttempts{2, Immediate{}}},
+	Args:   []policyArgs{{1, 0}, {2, 0}},
+	Wait:   []time.Duration{0, Stop},
+}, {
+	Name: "LimitDeadline",
+	Policy: useClock(LimitDeadline{time.Unix(0, 0).Add(90 * time.Second),
+		Constant(time.Minute)}, stoppedClock{time.Unix(0, 0)}),
+	Args: []policyArgs{{1, 0}, {2, time.Hour}},
+	Wait: []time.Duration{time.Minute, time.Minute},
+}, {
+	Name: "LimitDeadline/truncate",
+	Policy: useClock(LimitDeadline{time.Unix(0, 0).Add(30 * time.Second),
+		Constant(time.Minute)}, stoppedClock{time.Unix(0, 0)}),
+	Args: []policyArgs{{1, 0}},
+	Wait: []time.Duration{30 * time.Second},
+}, {
+	Name: "LimitDeadline/past",
+	Policy: useClock(LimitDeadline{time.Unix(0, 0),
+		Constant(time.Minute)}, stoppedClock{time.Unix(0, 0)}),
+	Args: []policyArgs{{1, 0}},
+	Wait: []time.Duration{Stop},
+}, {
+	Name:   "LimitDeadline/system clock",
+	Policy: LimitDeadline{time.Now().Add(time.Hour), Constant(time.Minute)},
+	Args:   []policyArgs{{1, 0}},
+	Wait:   []time.Duration{time.Minute},
+}, {
+	Name:   "Schedule",
+	Policy: Schedule{[]time.Duration{time.Second, time.Minute}, nil},
+	Args:   []policyArgs{{0, 0}, {1, 0}, {2, 0}, {3, 0}, {100, 0}},
+	Wait: []time.Duration{time.Second, time.Second, time.Minute,
+		time.Minute, time.Minute},
+}, {
+	Name:   "Schedule/stop",
+	Policy: Schedule{[]time.Duration{time.Second}, Constant(Stop)},
+	Args:   []policyArgs{{1, 0}, {2, 0}},
+	Wait:   []time.Duration{time.Second, Stop},
+}, {
+	Name: "Schedule/Then",
+	Policy: Schedule{[]time.Duration{time.Second, time.Second},
+		Exponential{time.Minute, 2}},
+	Args: []policyArgs{{2, 0}, {3, 0}, {4, 0}, {5, 0}},
+	Wait: []time.Duration{time.Second, time.Minute, 2 * time.Minute,
+		4 * time.Minute},
+}, {
+	Name:   "Schedule/empty",
+	Policy: Schedule{},
+	Args:   []policyArgs{{1, 0}},
+	Wait:   []time.Duration{Stop},
+}, {
+	Name: "Phases",
+	Policy: Phases{
+		{3, 0, Immediate{}},
+		{4, 0, Exponential{time.Second, 2}},
+		{0, 0, Constant(time.Minute)},
+	},
+	Args: []policyArgs{{1, 0}, {3, 0}, {4, 0}, {5, 0}, {7, 0}, {8, 0},
+		{100, 0}},
+	Wait: []time.Duration{0, 0, time.Second, 2 * time.Second,
+		8 * time.Second, time.Minute, time.Minute},
+}, {
+	Name:   "Phases/stop",
+	Policy: Phases{{2, 0, Constant(time.Second)}},
+	Args:   []policyArgs{{2, 0}, {3, 0}},
+	Wait:   []time.Duration{time.Second, Stop},
 }, {
 	Name:   "LimitAttempts",
 	Policy: LimitAttempts{2, Constant(time.Minute)},
@@ -108,4 +260,155 @@ var policyTests = []policyTest{{
 	Policy: Max{90 * time.Second, Linear{time.Minute, time.Minute}},
 	Args:   []policyArgs{{1, 0}, {2, 30 * time.Second}},
 	Wait:   []time.Duration{time.Minute, 90 * time.Second},
+}, {
+	Name:   "Min",
+	Policy: Min{time.Minute, Linear{0, 45 * time.Second}},
+	Args:   []policyArgs{{1, 0}, {2, 0}, {3, 0}},
+	Wait:   []time.Duration{time.Minute, time.Minute, 90 * time.Second},
+}, {
+	Name:   "Min/stop",
+	Policy: Min{time.Minute, Constant(Stop)},
+	Args:   []policyArgs{{1, 0}},
+	Wait:   []time.Duration{Stop},
+}, {
+	Name:   "Offset",
+	Policy: Offset{time.Second, Linear{time.Minute, time.Minute}},
+	Args:   []policyArgs{{1, 0}, {2, 0}},
+	Wait:   []time.Duration{time.Minute + time.Second, 2*time.Minute + time.Second},
+}, {
+	Name:   "Offset/negative",
+	Policy: Offset{-time.Hour, Constant(time.Minute)},
+	Args:   []policyArgs{{1, 0}},
+	Wait:   []time.Duration{0},
+}, {
+	Name:   "Offset/overflow",
+	Policy: Offset{time.Second, Constant(math.MaxInt64)},
+	Args:   []policyArgs{{1, 0}},
+	Wait:   []time.Duration{math.MaxInt64},
+}, {
+	Name:   "Offset/stop",
+	Policy: Offset{time.Second, Constant(Stop)},
+	Args:   []policyArgs{{1, 0}},
+	Wait:   []time.Duration{Stop},
+}, {
+	Name:   "Scale",
+	Policy: Scale{2.5, Constant(time.Minute)},
+	Args:   []policyArgs{{1, 0}},
+	Wait:   []time.Duration{150 * time.Second},
+}, {
+	Name:   "Scale/overflow",
+	Policy: Scale{2, Constant(math.MaxInt64/2 + 1)},
+	Args:   []policyArgs{{1, 0}},
+	Wait:   []time.Duration{math.MaxInt64},
+}, {
+	Name:   "Scale/negative",
+	Policy: Scale{-1, Constant(time.Minute)},
+	Args:   []policyArgs{{1, 0}},
+	Wait:   []time.Duration{0},
+}, {
+	Name:   "Scale/stop",
+	Policy: Scale{2, Constant(Stop)},
+	Args:   []policyArgs{{1, 0}},
+	Wait:   []time.Duration{Stop},
+}, {
+	Name:   "Sum",
+	Policy: Sum{Constant(time.Minute), Linear{0, time.Second}},
+	Args:   []policyArgs{{1, 0}, {3, 0}},
+	Wait:   []time.Duration{time.Minute, time.Minute + 2*time.Second},
+}, {
+	Name:   "Sum/overflow",
+	Policy: Sum{Constant(math.MaxInt64), Constant(1)},
+	Args:   []policyArgs{{1, 0}},
+	Wait:   []time.Duration{math.MaxInt64},
+}, {
+	Name:   "Sum/stop",
+	Policy: Sum{Constant(time.Minute), LimitAttempts{2, Immediate{}}},
+	Args:   []policyArgs{{1, 0}, {2, 0}},
+	Wait:   []time.Duration{time.Minute, Stop},
+}, {
+	Name:   "Sum/empty",
+	Policy: Sum{},
+	Args:   []policyArgs{{1, 0}},
+	Wait:   []time.Duration{Stop},
+}, {
+	Name:   "LargestOf",
+	Policy: LargestOf{Constant(time.Minute), Linear{0, time.Minute}},
+	Args:   []policyArgs{{1, 0}, {2, 0}, {3, 0}},
+	Wait:   []time.Duration{time.Minute, time.Minute, 2 * time.Minute},
+}, {
+	Name:   "LargestOf/stop",
+	Policy: LargestOf{Constant(time.Minute), LimitAttempts{2, Immediate{}}},
+	Args:   []policyArgs{{1, 0}, {2, 0}},
+	Wait:   []time.Duration{time.Minute, Stop},
+}, {
+	Name:   "LargestOf/empty",
+	Policy: LargestOf{},
+	Args:   []policyArgs{{1, 0}},
+	Wait:   []time.Duration{Stop},
+}, {
+	Name:   "SmallestOf",
+	Policy: SmallestOf{Constant(time.Minute), Linear{0, time.Minute}},
+	Args:   []policyArgs{{1, 0}, {2, 0}, {3, 0}},
+	Wait:   []time.Duration{0, time.Minute, time.Minute},
+}, {
+	Name:   "SmallestOf/stop",
+	Policy: SmallestOf{Constant(time.Minute), LimitAttempts{2, Immediate{}}},
+	Args:   []policyArgs{{1, 0}, {2, 0}},
+	Wait:   []time.Duration{0, Stop},
+}, {
+	Name:   "SmallestOf/empty",
+	Policy: SmallestOf{},
+	Args:   []policyArgs{{1, 0}},
+	Wait:   []time.Duration{Stop},
 }}
+
+// exponentialLoop is the original per attempt loop of Exponential.Wait, which
+// Exponential.Wait must match for small attempts.
+func exponentialLoop(e Exponential, attempts uint) time.Duration {
+	wait := float64(e.Initial)
+	overflow := math.MaxInt64 / e.Multiplier
+	for i := uint(1); i < attempts; i++ {
+		if wait == 0 || wait > overflow {
+			break
+		}
+		wait *= e.Multiplier
+	}
+	return time.Duration(wait)
+}
+
+func TestExponentialLoop(t *testing.T) {
+	for _, e := range []Exponential{
+		{time.Millisecond, 2},
+		{time.Second, 1.5},
+		{500 * time.Millisecond, 1.1},
+		{3 * time.Millisecond, math.Pi},
+		{time.Minute, .9},
+	} {
+		for attempts := uint(1); attempts <= exponentialSteps+1; attempts++ {
+			want := exponentialLoop(e, attempts)
+			if float64(e.Initial)*math.Pow(e.Multiplier,
+				float64(attempts)) >= math.MaxInt64 {
+				// The loop may stop short of the limit.
+				break
+			}
+			assert.Equal(t, want, e.Wait(attempts, 0),
+				"%v attempts: %v", e, attempts)
+		}
+	}
+}
+
+func BenchmarkExponential(b *testing.B) {
+	e := Exponential{time.Millisecond, 1.000001}
+	for _, attempts := range []uint{10, 1000, 1000000} {
+		b.Run(fmt.Sprintf("Wait/%v", attempts), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				e.Wait(attempts, 0)
+			}
+		})
+		b.Run(fmt.Sprintf("loop/%v", attempts), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				exponentialLoop(e, attempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright 2019 Adam S Levy
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package retry
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Rand is a source of random numbers for the Randomize, FullJitter,
+// EqualJitter and DecorrelatedJitter Policies, given to them by their WithRand
+// method.
+//
+// WithRand takes a func returning a new Rand, which is called once for each
+// call to Run, so that concurrent calls to Run never share a Rand. Outside of
+// Run, it is called for each wait. A *rand.Rand from math/rand implements
+// Rand, so a func returning a *rand.Rand with a fixed seed gives reproducible
+// waits for every Run, for example in tests. Use CryptoRand for a source
+// backed by crypto/rand.
+//
+// If the func or the Rand it returns is nil, the global source of math/rand is
+// used.
+type Rand interface {
+	// Float64 returns a random number in the range [0, 1).
+	Float64() float64
+}
+
+// CryptoRand is a Rand backed by crypto/rand. It is safe for concurrent use.
+var CryptoRand Rand = cryptoRand{}
+
+type cryptoRand struct{}
+
+// Float64 returns a random number in the range [0, 1) with 53 bits of
+// precision read from crypto/rand. It panics if crypto/rand fails.
+func (cryptoRand) Float64() float64 {
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return float64(binary.LittleEndian.Uint64(b[:])>>11) / (1 << 53)
+}
+
+// randomizer is implemented by the random Policies of this package, which draw
+// their waits from dist using r. Like waitExtended, waitRand also reports
+// whether the wait was extended by a Window.
+type randomizer interface {
+	Policy
+	waitRand(r Rand, dist Distribution, err error,
+		attempts uint, total time.Duration) (time.Duration, bool)
+}
+
+// randPolicy is the Policy returned by WithRand.
+type randPolicy struct {
+	policy  randomizer
+	newRand func() Rand
+	dist    Distribution
+
+	// rand is the Rand of the current session, if any.
+	rand Rand
+}
+
+func (p randPolicy) Wait(attempts uint, total time.Duration) time.Duration {
+	return p.WaitErr(nil, attempts, total)
+}
+
+func (p randPolicy) WaitErr(err error,
+	attempts uint, total time.Duration) time.Duration {
+
+	wait, _ := p.waitExtended(err, attempts, total)
+	return wait
+}
+
+func (p randPolicy) waitExtended(err error,
+	attempts uint, total time.Duration) (time.Duration, bool) {
+
+	r := p.rand
+	if r == nil && p.newRand != nil {
+		r = p.newRand()
+	}
+	return p.policy.waitRand(r, p.dist, err, attempts, total)
+}
+
+// NewSession returns a session of p with its own Rand.
+func (p randPolicy) NewSession() Policy {
+	p.policy = NewSession(p.policy).(randomizer)
+	if p.newRand != nil {
+		p.rand = p.newRand()
+	}
+	return p
+}
+
+// globalRand is the Rand used when none is given.
+type globalRand struct{}
+
+func (globalRand) Float64() float64 { return rand.Float64() }
+
+// Distribution determines how random wait times are spread across their
+// range.
+//
+// If a Distribution is nil, Uniform is used.
+type Distribution interface {
+	// Sample returns a number in the range [0, 1] drawn using r, which
+	// is then scaled to the range of possible wait times.
+	Sample(r Rand) float64
+}
+
+// Uniform is a Distribution where every wait time in the range is equally
+// likely.
+type Uniform struct{}
+
+// Sample returns r.Float64().
+func (Uniform) Sample(r Rand) float64 {
+	return r.Float64()
+}
+
+// TruncatedNormal is a Distribution where wait times cluster around the
+// middle of the range. It is a normal distribution with a mean of 0.5 and a
+// standard deviation of StdDev, truncated to the range [0, 1].
+//
+// A small StdDev, such as 0.1, keeps most wait times near the middle. A large
+// StdDev approaches Uniform. If StdDev is not greater than 0, the middle of the
+// range is always selected.
+type TruncatedNormal struct {
+	StdDev float64
+}
+
+// Sample returns a number drawn from the truncated normal distribution by
+// inverting its cumulative distribution function, so r is only called once.
+func (n TruncatedNormal) Sample(r Rand) float64 {
+	if !(n.StdDev > 0) {
+		return .5
+	}
+	// The cumulative distribution function of the bounds 0 and 1, which
+	// are symmetric about the mean.
+	hi := .5 * (1 + math.Erf(.5/(n.StdDev*math.Sqrt2)))
+	lo := 1 - hi
+	u := lo + r.Float64()*(hi-lo)
+	return clamp01(.5 + n.StdDev*math.Sqrt2*math.Erfinv(2*u-1))
+}
+
+// TruncatedExponential is a Distribution where shorter wait times are more
+// likely than longer ones. It is an exponential distribution with a rate of
+// Rate, scaled to the range [0, 1] and truncated to it.
+//
+// Exponentially distributed waits model clients that retry as a Poisson
+// process, which spreads retries from many clients evenly over time. A larger
+// Rate favors shorter waits more strongly. If Rate is not greater than 0,
+// TruncatedExponential is equivalent to Uniform.
+type TruncatedExponential struct {
+	Rate float64
+}
+
+// Sample returns a number drawn from the truncated exponential distribution
+// by inverting its cumulative distribution function, so r is only called
+// once.
+func (e TruncatedExponential) Sample(r Rand) float64 {
+	u := r.Float64()
+	if !(e.Rate > 0) {
+		return u
+	}
+	return clamp01(-math.Log1p(u*math.Expm1(-e.Rate)) / e.Rate)
+}
+
+// clamp01 returns f limited to the range [0, 1].
+func clamp01(f float64) float64 {
+	if f < 0 {
+		return 0
+	}
+	if f > 1 {
+		return 1
+	}
+	return f
+}
+
+// sample returns a number in the range [0, 1] drawn from dist using r,
+// defaulting to Uniform and the global source of math/rand.
+func sample(r Rand, dist Distribution) float64 {
+	if r == nil {
+		r = globalRand{}
+	}
+	if dist == nil {
+		dist = Uniform{}
+	}
+	return clamp01(dist.Sample(r))
+}
+
+// randDuration returns a duration randomly selected from the range [min,
+// max] using r and dist. The caller must ensure that 0 <= min <= max.
+func randDuration(r Rand, dist Distribution,
+	min, max time.Duration) time.Duration {
+
+	// Add 1 to account for truncation of float64 into int64, so that
+	// every duration in the range is equally likely under Uniform.
+	wait := min + floatDuration(sample(r, dist)*(float64(max-min)+1))
+	if wait < min || wait > max {
+		// Either sample returned 1 or the addition overflowed.
+		return max
+	}
+	return wait
+}
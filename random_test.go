@@ -0,0 +1,167 @@
+// Copyright 2019 Adam S Levy
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package retry
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistribution(t *testing.T) {
+	for _, test := range []struct {
+		Name         string
+		Distribution Distribution
+		Min, Max     float64 // Bounds of the mean of many samples.
+	}{
+		{"Uniform", Uniform{}, .45, .55},
+		{"TruncatedNormal", TruncatedNormal{.1}, .48, .52},
+		{"TruncatedNormal/wide", TruncatedNormal{100}, .45, .55},
+		{"TruncatedNormal/zero", TruncatedNormal{}, .5, .5},
+		{"TruncatedNormal/negative", TruncatedNormal{-1}, .5, .5},
+		{"TruncatedNormal/NaN", TruncatedNormal{math.NaN()}, .5, .5},
+		{"TruncatedExponential", TruncatedExponential{5}, .15, .25},
+		{"TruncatedExponential/zero", TruncatedExponential{}, .45, .55},
+		{"TruncatedExponential/negative", TruncatedExponential{-1},
+			.45, .55},
+		{"TruncatedExponential/NaN", TruncatedExponential{math.NaN()},
+			.45, .55},
+	} {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			assert := assert.New(t)
+			r := rand.New(rand.NewSource(1))
+			var sum float64
+			const n = 10000
+			for i := 0; i < n; i++ {
+				f := test.Distribution.Sample(r)
+				assert.True(0 <= f && f <= 1, f)
+				sum += f
+			}
+			mean := sum / n
+			assert.True(test.Min <= mean && mean <= test.Max, mean)
+		})
+	}
+	t.Run("bounds", func(t *testing.T) {
+		assert := assert.New(t)
+		for _, u := range []float64{0, math.Nextafter(1, 0)} {
+			r := constRand(u)
+			for _, dist := range []Distribution{Uniform{},
+				TruncatedNormal{.1}, TruncatedExponential{5}} {
+				f := dist.Sample(r)
+				assert.True(0 <= f && f <= 1, "%T %v", dist, f)
+			}
+		}
+	})
+}
+
+func TestRand(t *testing.T) {
+	newRand := func() Rand { return rand.New(rand.NewSource(1)) }
+	t.Run("seeded", func(t *testing.T) {
+		assert := assert.New(t)
+		for _, policy := range []Policy{
+			Randomize{.5, Constant(time.Minute)}.WithRand(newRand, nil),
+			FullJitter{Constant(time.Minute)}.WithRand(newRand, nil),
+			EqualJitter{Constant(time.Minute)}.WithRand(newRand,
+				TruncatedNormal{.2}),
+			DecorrelatedJitter{time.Second, 0}.WithRand(newRand,
+				TruncatedExponential{2}),
+		} {
+			assert.Equal(policy.Wait(1, 0), policy.Wait(1, 0), "%T", policy)
+			a, b := NewSession(policy), NewSession(policy)
+			for attempts := uint(1); attempts < 10; attempts++ {
+				assert.Equal(a.Wait(attempts, 0),
+					b.Wait(attempts, 0), "%T", policy)
+			}
+		}
+	})
+	t.Run("concurrent", func(t *testing.T) {
+		assert := assert.New(t)
+		policy := LimitAttempts{5,
+			Randomize{.5, Constant(time.Minute)}.WithRand(newRand, nil)}
+		run := func() []time.Duration {
+			var waits []time.Duration
+			notify := func(_ error, _ uint, d time.Duration) {
+				waits = append(waits, d)
+			}
+			Run(nil, policy, nil, notify, func() error {
+				return fmt.Errorf("failed")
+			}, WithClock(newMockClock()))
+			return waits
+		}
+		want := run()
+		results := make(chan []time.Duration)
+		for i := 0; i < 4; i++ {
+			go func() { results <- run() }()
+		}
+		for i := 0; i < 4; i++ {
+			assert.Equal(want, <-results)
+		}
+	})
+	t.Run("bounds", func(t *testing.T) {
+		assert := assert.New(t)
+		for _, u := range []float64{0, math.Nextafter(1, 0)} {
+			r := constRand(u)
+			for _, dist := range []Distribution{Uniform{},
+				ceilDistribution{}} {
+				newRand := func() Rand { return r }
+				policy := Randomize{.5, Constant(time.Minute)}.
+					WithRand(newRand, dist)
+				wait := policy.Wait(1, 0)
+				assert.True(30*time.Second <= wait &&
+					wait <= 90*time.Second, wait)
+
+				wait = FullJitter{Constant(math.MaxInt64)}.
+					WithRand(newRand, dist).Wait(1, 0)
+				assert.True(wait >= 0, wait)
+
+				wait = EqualJitter{Constant(time.Minute)}.
+					WithRand(newRand, dist).Wait(1, 0)
+				assert.True(time.Minute/2 <= wait &&
+					wait <= time.Minute, wait)
+			}
+		}
+	})
+	t.Run("CryptoRand", func(t *testing.T) {
+		for i := 0; i < 1000; i++ {
+			f := CryptoRand.Float64()
+			assert.True(t, 0 <= f && f < 1, f)
+		}
+		policy := FullJitter{Constant(time.Minute)}.
+			WithRand(func() Rand { return CryptoRand }, nil)
+		wait := policy.Wait(1, 0)
+		assert.True(t, 0 <= wait && wait <= time.Minute, wait)
+	})
+}
+
+// constRand is a Rand that always returns the same number.
+type constRand float64
+
+func (r constRand) Float64() float64 { return float64(r) }
+
+// ceilDistribution is a Distribution that always returns 1.
+type ceilDistribution struct{}
+
+func (ceilDistribution) Sample(Rand) float64 { return 1 }
@@ -0,0 +1,89 @@
+// Copyright 2019 Adam S Levy
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package retry
+
+import "time"
+
+// Result describes the outcome of a call to Run. Use WithResult to obtain
+// it.
+type Result struct {
+	// Reason Run returned.
+	Reason Reason
+
+	// Attempts is the number of times op was called.
+	Attempts uint
+
+	// Total is the time elapsed between the start of Run and its return.
+	Total time.Duration
+
+	// Waited is the total time spent waiting between attempts.
+	Waited time.Duration
+
+	// SkippedWait is the wait that was cut short when ctx.Done() was
+	// closed, or the wait that was not started due to the deadline of
+	// ctx, or 0 if no wait was skipped.
+	SkippedWait time.Duration
+}
+
+// Reason describes why Run returned.
+type Reason int
+
+// The possible Reasons for Run to return.
+const (
+	// ReasonSuccess means the latest filtered op error was nil.
+	ReasonSuccess Reason = iota
+
+	// ReasonPolicyStop means the Policy returned Stop.
+	ReasonPolicyStop
+
+	// ReasonErrorStop means op or filter returned an error wrapped by
+	// ErrorStop.
+	ReasonErrorStop
+
+	// ReasonContextError means op returned context.Canceled or
+	// context.DeadlineExceeded.
+	ReasonContextError
+
+	// ReasonContextDone means ctx.Done() was closed while waiting or
+	// before an attempt.
+	ReasonContextDone
+
+	// ReasonDeadline means the next wait would not have ended in time
+	// for another attempt before the deadline of ctx. See
+	// WithDeadlineMode.
+	ReasonDeadline
+)
+
+var reasonStrings = [...]string{
+	ReasonSuccess:      "success",
+	ReasonPolicyStop:   "policy stop",
+	ReasonErrorStop:    "error stop",
+	ReasonContextError: "context error",
+	ReasonContextDone:  "context done",
+	ReasonDeadline:     "deadline",
+}
+
+func (r Reason) String() string {
+	if r < 0 || int(r) >= len(reasonStrings) {
+		return "unknown reason"
+	}
+	return reasonStrings[r]
+}
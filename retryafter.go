@@ -0,0 +1,81 @@
+// Copyright 2019 Adam S Levy
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package retry
+
+import (
+	"errors"
+	"time"
+)
+
+// RetryAfter wraps err such that when returned from an op or filter, Run
+// waits d before the next attempt instead of the wait of its Policy. Use it
+// when the server says exactly when to retry, such as with an HTTP
+// Retry-After header.
+//
+// The RetryAfter may be anywhere in the chain of the returned error. The wait
+// d replaces the wait of the innermost Policy, so wrappers such as Max,
+// LimitTotal and LimitAttempts still apply, but jitter is not added, nor is it
+// changed by Scale, Offset or Sum. If the innermost Policy returns Stop, Run
+// still stops. The wait is passed to notify as usual.
+//
+// If d is negative, it is treated as 0.
+func RetryAfter(err error, d time.Duration) error {
+	if d < 0 {
+		d = 0
+	}
+	return retryAfter{err, d}
+}
+
+// RetryAfterDuration returns the wait of the first error wrapped by
+// RetryAfter in the chain of err, if any.
+func RetryAfterDuration(err error) (time.Duration, bool) {
+	var ra retryAfter
+	if err == nil || errors.As(err, new(ignoreRetryAfter)) ||
+		!errors.As(err, &ra) {
+		return 0, false
+	}
+	return ra.d, true
+}
+
+// ignoreRetryAfter wraps an error such that RetryAfterDuration ignores any
+// RetryAfter in its chain, while errors.Is and errors.As still see the rest.
+// Run uses it for the error passed to the attempt timeout Policy, which must
+// not be replaced by a RetryAfter wait.
+type ignoreRetryAfter struct {
+	error
+}
+
+func (e ignoreRetryAfter) Unwrap() error {
+	return e.error
+}
+
+type retryAfter struct {
+	err error
+	d   time.Duration
+}
+
+func (e retryAfter) Error() string {
+	return e.err.Error()
+}
+
+func (e retryAfter) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,133 @@
+// Copyright 2019 Adam S Levy
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryAfter(t *testing.T) {
+	err := fmt.Errorf("test")
+	t.Run("Error", func(t *testing.T) {
+		assert := assert.New(t)
+		ra := RetryAfter(err, time.Minute)
+		assert.EqualError(ra, err.Error())
+		assert.True(errors.Is(ra, err))
+
+		wait, ok := RetryAfterDuration(fmt.Errorf("wrapped: %w", ra))
+		assert.True(ok)
+		assert.Equal(time.Minute, wait)
+
+		wait, ok = RetryAfterDuration(RetryAfter(err, -time.Minute))
+		assert.True(ok)
+		assert.Equal(time.Duration(0), wait)
+
+		_, ok = RetryAfterDuration(err)
+		assert.False(ok)
+		_, ok = RetryAfterDuration(nil)
+		assert.False(ok)
+	})
+	t.Run("WaitErr", func(t *testing.T) {
+		assert := assert.New(t)
+		ra := fmt.Errorf("wrapped: %w", RetryAfter(err, time.Hour))
+		for _, test := range []struct {
+			Policy Policy
+			Wait   time.Duration
+		}{
+			{Constant(time.Second), time.Hour},
+			{Randomize{.5, Constant(time.Second)}, time.Hour},
+			{FullJitter{Constant(time.Second)}, time.Hour},
+			{EqualJitter{Constant(time.Second)}, time.Hour},
+			{DecorrelatedJitter{time.Second, time.Minute}, time.Hour},
+			{NewSession(DecorrelatedJitter{time.Second, time.Minute}),
+				time.Hour},
+			{Randomize{.5, Constant(time.Second)}.WithRand(nil, nil),
+				time.Hour},
+			{FullJitter{Constant(time.Second)}.WithRand(nil, nil),
+				time.Hour},
+			{EqualJitter{Constant(time.Second)}.WithRand(nil, nil),
+				time.Hour},
+			{DecorrelatedJitter{time.Second, time.Minute}.
+				WithRand(nil, nil), time.Hour},
+			{NewSession(DecorrelatedJitter{time.Second, time.Minute}.
+				WithRand(nil, nil)), time.Hour},
+			{Max{time.Minute, Constant(time.Second)}, time.Minute},
+			{Scale{2, Constant(time.Second)}, time.Hour},
+			{Offset{time.Minute, Constant(time.Second)}, time.Hour},
+			{Sum{Constant(time.Second), Constant(time.Second)}, time.Hour},
+			{Sum{Constant(time.Second), Constant(Stop)}, Stop},
+			{LimitTotal{time.Minute, Constant(time.Second)}, Stop},
+			{LimitAttempts{1, Constant(time.Second)}, Stop},
+			{Switch{Default: Constant(time.Second)}, time.Hour},
+			{Constant(Stop), Stop},
+			{Schedule{Then: Constant(Stop)}, Stop},
+			{wrapperPolicy{LimitAttempts{1, Constant(time.Second)}}, Stop},
+		} {
+			wait := WaitErr(test.Policy, ra, 1, time.Hour)
+			assert.Equalf(test.Wait, wait, "%#v", test.Policy)
+		}
+	})
+	t.Run("Run", func(t *testing.T) {
+		assert := assert.New(t)
+		var waits []time.Duration
+		notify := func(_ error, _ uint, d time.Duration) {
+			waits = append(waits, d)
+		}
+		ops := []error{err, RetryAfter(err, time.Hour), err}
+		var i int
+		runErr := Run(nil, LimitAttempts{3, Max{2 * time.Hour,
+			Constant(time.Second)}}, nil, notify, func() error {
+			defer func() { i++ }()
+			return ops[i]
+		}, WithClock(newMockClock()))
+		assert.Equal(err, runErr)
+		assert.Equal([]time.Duration{time.Second, time.Hour}, waits)
+	})
+	t.Run("Run/stop", func(t *testing.T) {
+		sched, parseErr := ParseSchedule("1ms, 1ms")
+		if !assert.NoError(t, parseErr) {
+			return
+		}
+		for _, policy := range []Policy{
+			sched,
+			wrapperPolicy{LimitAttempts{3, Constant(time.Second)}},
+		} {
+			var attempts int
+			runErr := Run(nil, policy, nil, nil, func() error {
+				attempts++
+				if attempts > 10 {
+					return ErrorStop(err)
+				}
+				return RetryAfter(err, time.Millisecond)
+			}, WithClock(newMockClock()))
+			assert.True(t, errors.Is(runErr, err))
+			assert.Equalf(t, 3, attempts, "%#v", policy)
+		}
+	})
+}
+
+// wrapperPolicy is a custom wrapper Policy that is not an ErrorPolicy.
+type wrapperPolicy struct{ Policy }
@@ -0,0 +1,172 @@
+// Copyright 2019 Adam S Levy
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+// Package retrytest provides utilities for testing code that uses package
+// retry.
+package retrytest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AdamSLevy/retry"
+)
+
+// FakeClock is a retry.Clock whose time only moves forward when Advance is
+// called. Pass it to retry.Run using retry.WithClock to test retry loops
+// without sleeping in real time.
+//
+// A FakeClock is safe for concurrent use, including by concurrent calls to
+// retry.Run. It must be created with NewFakeClock.
+type FakeClock struct {
+	mu      sync.Mutex
+	waiting *sync.Cond
+	now     time.Time
+	timers  []*fakeTimer
+}
+
+var _ retry.Clock = (*FakeClock)(nil)
+
+// NewFakeClock returns a FakeClock whose current time is now.
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.waiting = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the current fake time.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer returns a retry.Timer that fires once the clock has been advanced
+// by at least d.
+func (c *FakeClock) NewTimer(d time.Duration) retry.Timer {
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1)}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.schedule(t, d)
+	return t
+}
+
+// Advance moves the current time forward by d and fires all timers whose
+// deadline has been reached, in order of their deadlines.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].deadline.Before(c.timers[j].deadline)
+	})
+	var i int
+	for ; i < len(c.timers); i++ {
+		t := c.timers[i]
+		if t.deadline.After(c.now) {
+			break
+		}
+		t.fire(c.now)
+	}
+	c.timers = c.timers[i:]
+}
+
+// BlockUntil blocks until at least n timers are waiting to fire.
+//
+// This allows a test to wait until retry.Run has started waiting before
+// calling Advance.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.waiting.Wait()
+	}
+}
+
+// Waiters returns the number of timers that are waiting to fire.
+func (c *FakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// schedule t to fire after d. The caller must hold c.mu.
+func (c *FakeClock) schedule(t *fakeTimer, d time.Duration) {
+	if d <= 0 {
+		t.fire(c.now)
+		return
+	}
+	t.deadline = c.now.Add(d)
+	c.timers = append(c.timers, t)
+	c.waiting.Broadcast()
+}
+
+// remove t from the waiting timers and report whether it was waiting. The
+// caller must hold c.mu.
+func (c *FakeClock) remove(t *fakeTimer) bool {
+	for i, w := range c.timers {
+		if w == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTimer struct {
+	clock    *FakeClock
+	c        chan time.Time
+	deadline time.Time
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	active := t.clock.remove(t)
+	t.drain()
+	t.clock.schedule(t, d)
+	return active
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	active := t.clock.remove(t)
+	t.drain()
+	return active
+}
+
+func (t *fakeTimer) GetC() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) fire(now time.Time) {
+	t.drain()
+	t.c <- now
+}
+
+func (t *fakeTimer) drain() {
+	select {
+	case <-t.c:
+	default:
+	}
+}
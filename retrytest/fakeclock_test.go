@@ -0,0 +1,125 @@
+// Copyright 2019 Adam S Levy
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package retrytest_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/AdamSLevy/retry"
+	"github.com/AdamSLevy/retry/retrytest"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Unix(0, 0)
+	t.Run("Advance", func(t *testing.T) {
+		assert := assert.New(t)
+		clock := retrytest.NewFakeClock(start)
+		tmr := clock.NewTimer(time.Minute)
+		assert.Equal(1, clock.Waiters())
+
+		clock.Advance(59 * time.Second)
+		assert.Len(tmr.GetC(), 0)
+
+		clock.Advance(time.Second)
+		assert.Equal(start.Add(time.Minute), <-tmr.GetC())
+		assert.Equal(0, clock.Waiters())
+	})
+	t.Run("Stop", func(t *testing.T) {
+		assert := assert.New(t)
+		clock := retrytest.NewFakeClock(start)
+		tmr := clock.NewTimer(time.Minute)
+		assert.True(tmr.Stop())
+		assert.False(tmr.Stop())
+		clock.Advance(time.Hour)
+		assert.Len(tmr.GetC(), 0)
+	})
+	t.Run("Reset", func(t *testing.T) {
+		assert := assert.New(t)
+		clock := retrytest.NewFakeClock(start)
+		tmr := clock.NewTimer(0)
+		assert.Equal(start, <-tmr.GetC())
+		assert.False(tmr.Reset(time.Minute))
+		assert.True(tmr.Reset(time.Hour))
+		clock.Advance(time.Minute)
+		assert.Len(tmr.GetC(), 0)
+		clock.Advance(time.Hour)
+		assert.Equal(start.Add(time.Hour+time.Minute), <-tmr.GetC())
+	})
+}
+
+func TestFakeClockRun(t *testing.T) {
+	clock := retrytest.NewFakeClock(time.Unix(0, 0))
+	policy := retry.LimitAttempts{Limit: 3, Policy: retry.Constant(time.Minute)}
+
+	const runs = 10
+	errs := make(chan error, runs)
+	for i := 0; i < runs; i++ {
+		go func() {
+			errs <- retry.Run(context.Background(), policy, nil, nil,
+				func() error { return fmt.Errorf("failed") },
+				retry.WithClock(clock))
+		}()
+	}
+
+	// Each Run waits twice before LimitAttempts stops it.
+	for i := 0; i < 2; i++ {
+		clock.BlockUntil(runs)
+		clock.Advance(time.Minute)
+	}
+
+	for i := 0; i < runs; i++ {
+		assert.EqualError(t, <-errs, "failed")
+	}
+	assert.Equal(t, time.Unix(0, 0).Add(2*time.Minute), clock.Now())
+}
+
+func TestFakeClockRunCanceled(t *testing.T) {
+	assert := assert.New(t)
+	clock := retrytest.NewFakeClock(time.Unix(0, 0))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var res retry.Result
+	done := make(chan error)
+	go func() {
+		done <- retry.Run(ctx, retry.Constant(time.Minute), nil, nil,
+			func() error { return fmt.Errorf("failed") },
+			retry.WithClock(clock), retry.WithResult(&res))
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(10 * time.Second)
+	cancel()
+
+	assert.EqualError(<-done, "failed: context canceled")
+	assert.Equal(retry.Result{
+		Reason:      retry.ReasonContextDone,
+		Attempts:    1,
+		Total:       10 * time.Second,
+		Waited:      10 * time.Second,
+		SkippedWait: time.Minute,
+	}, res)
+}
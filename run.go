@@ -31,47 +31,32 @@ import (
 	"time"
 )
 
-// Allow for tests to mock the time package.
-var (
-	timeNow      = time.Now
-	timeSince    = time.Since
-	timeNewTimer = newTimer
-)
-
-func newTimer(d time.Duration) timer {
-	return (*timeTimer)(time.NewTimer(d))
-}
-
-// timer allows time.Timer to be mocked in tests.
-type timer interface {
-	Reset(time.Duration) bool
-	Stop() bool
-	GetC() <-chan time.Time
-}
-
-type timeTimer time.Timer
-
-func (t *timeTimer) Reset(d time.Duration) bool {
-	return (*time.Timer)(t).Reset(d)
-}
-func (t *timeTimer) Stop() bool {
-	return (*time.Timer)(t).Stop()
-}
-func (t *timeTimer) GetC() <-chan time.Time {
-	return t.C
-}
-
 // Run op until one of the following occurs,
 //
-//      - op returns nil.
-//      - op returns context.Canceled or context.DeadlineExceeded.
+//      - op returns nil or an error wrapped by ErrorIgnore.
 //      - op returns an error wrapped by ErrorStop.
+//      - op returns context.Canceled or context.DeadlineExceeded.
 //      - p.Wait returns Stop.
 //      - ctx.Done() is closed.
 //
+// The ctx is checked before every attempt, including the first, so op is
+// never called once ctx.Done() is closed. If that occurs before the first
+// attempt, Run returns ctx.Err(). Otherwise Run returns the latest filtered op
+// error, as it does when ctx.Done() is closed while waiting.
+//
+// By default Run waits even if ctx will be done before the wait is over. Use
+// WithDeadlineMode to stop early or to shorten the final wait instead.
+//
+// Whenever Run returns because ctx.Done() is closed, the returned error also
+// wraps ctx.Err() and context.Cause(ctx), so errors.Is(err, context.Canceled)
+// distinguishes shutdown from a genuine op failure, while errors.Is still
+// matches the errors of op.
+//
 // If the above conditions are not met, then op is retried after waiting
 // p.Wait. The total number of attempts and the total time elapsed since Run
-// was envoked are passed to p.Wait. See Policy for more details.
+// was envoked are passed to p.Wait. See Policy for more details. If p is a
+// SessionPolicy, a new session of p is used for each call to Run. If p is an
+// ErrorPolicy, p.WaitErr is called with the latest filtered op error instead.
 //
 // If filter is not nil, all calls to op are wrapped by filter: filter(op()).
 // Use a filter to add special handling for certain errors. For example, a
@@ -81,6 +66,12 @@ func (t *timeTimer) GetC() <-chan time.Time {
 //
 // Run always returns the latest filtered op return value. If the error was
 // wrapped by ErrorStop, it is unwrapped, and the original error is returned.
+// ErrorStop and ErrorIgnore are detected anywhere in the error chain, but only
+// an outermost ErrorStop is unwrapped. If the error was wrapped by
+// ErrorIgnore, Run returns nil.
+//
+// If the filtered op error was wrapped by RetryAfter, its wait replaces the
+// wait of the innermost Policy. See WaitErr.
 //
 // If notify is not nil, it is called with the latest return values of op and
 // p.Wait prior to waiting.
@@ -88,53 +79,224 @@ func (t *timeTimer) GetC() <-chan time.Time {
 // If ctx is nil, context.Background() is used.
 //
 // If ctx.Done() is closed while waiting, Run returns immediately.
+//
+// Any opts are applied to alter the default behavior of Run. See Option. For
+// example, use WithResult to learn which of the above conditions occurred.
 func Run(ctx context.Context,
 	p Policy, filter func(error) error,
 	notify func(error, uint, time.Duration),
-	op func() error) error {
+	op func() error, opts ...Option) error {
+
+	return RunContext(ctx, p, filter, notify,
+		func(context.Context) error { return op() }, opts...)
+}
+
+// RunContext is like Run but passes a context.Context to each attempt of op.
+//
+// The context passed to op is derived from ctx. If WithAttemptTimeout or
+// WithAttemptTimeoutPolicy is used, it also has a per attempt deadline. If
+// the per attempt deadline expires, the attempt is treated as a regular
+// failure and op is retried, even if op returns context.DeadlineExceeded.
+// Only errors due to ctx itself cause RunContext to return immediately.
+//
+// The context passed to op also holds an Attempt describing the current
+// attempt. See AttemptFromContext.
+func RunContext(ctx context.Context,
+	p Policy, filter func(error) error,
+	notify func(error, uint, time.Duration),
+	op func(context.Context) error, opts ...Option) error {
+
+	_, err := RunValueContext(ctx, p, filter, notify,
+		func(ctx context.Context) (struct{}, error) {
+			return struct{}{}, op(ctx)
+		}, opts...)
+	return err
+}
+
+// RunValue is like Run but for an op that also returns a value.
+//
+// If an attempt succeeds, that is, its filtered error is nil, RunValue returns
+// the value returned by that same attempt. Otherwise the zero value of T is
+// returned along with the error, so a value from an earlier failed attempt is
+// never returned.
+//
+// The filter, notify, ErrorStop, context error and Option semantics are
+// identical to Run.
+func RunValue[T any](ctx context.Context,
+	p Policy, filter func(error) error,
+	notify func(error, uint, time.Duration),
+	op func() (T, error), opts ...Option) (T, error) {
+
+	return RunValueContext(ctx, p, filter, notify,
+		func(context.Context) (T, error) { return op() }, opts...)
+}
+
+// RunValueContext is like RunValue but passes a context.Context to each
+// attempt of op, in the same way as RunContext.
+func RunValueContext[T any](ctx context.Context,
+	p Policy, filter func(error) error,
+	notify func(error, uint, time.Duration),
+	op func(context.Context) (T, error), opts ...Option) (T, error) {
 
 	if ctx == nil {
 		ctx = context.Background()
 	}
 
-	filterOp := op
-	if filter != nil {
-		filterOp = func() error { return filter(op()) }
+	cfg := newConfig(opts)
+	clock := cfg.clock
+
+	// Only a stateless and deterministic p can predict the final attempt.
+	canPredict := predictable(p)
+	p = NewSession(useClock(p, clock))
+	var timeout Policy
+	if cfg.attemptTimeout != nil {
+		timeout = NewSession(useClock(cfg.attemptTimeout, clock))
+	}
+
+	// The timer is created on the first wait so that a stale tick can
+	// never be received from a timer that fired before it was Reset.
+	var tmr Timer
+	defer func() {
+		if tmr != nil {
+			tmr.Stop()
+		}
+	}()
+
+	var zero T
+	start := clock.Now()
+
+	var res Result
+	if cfg.result != nil {
+		defer func() {
+			res.Total = clock.Now().Sub(start)
+			*cfg.result = res
+		}()
 	}
 
-	tmr := timeNewTimer(0)
-	defer tmr.Stop()
+	var history *HistoryError
+	if cfg.history {
+		history = new(HistoryError)
+	}
+	// fail returns err, or the history of all attempts if enabled.
+	fail := func(err error) (T, error) {
+		if history != nil {
+			history.Total = clock.Now().Sub(start)
+			return zero, history
+		}
+		return zero, err
+	}
+	// done is like fail but also wraps the reason that ctx is done.
+	done := func(err error) (T, error) {
+		_, err = fail(err)
+		return zero, contextDone(ctx, err)
+	}
 
-	start := timeNow()
 	var attempt uint
+	var prevErr error
+	var prevWait time.Duration
 	for {
-		err := filterOp()
-		if err == nil {
-			return nil
+		// Do not start an attempt once ctx is done.
+		if ctx.Err() != nil {
+			res.Reason = ReasonContextDone
+			if attempt == 0 {
+				return zero, contextDone(ctx, nil)
+			}
+			return done(prevErr)
+		}
+
+		res.Attempts++
+		opStart := clock.Now()
+		elapsed := opStart.Sub(start)
+
+		a := Attempt{
+			Number:   attempt + 1,
+			Start:    start,
+			Elapsed:  elapsed,
+			PrevErr:  prevErr,
+			PrevWait: prevWait,
+		}
+		if canPredict {
+			a.final = func() (bool, bool) {
+				return p.Wait(a.Number, a.Elapsed) <= Stop, true
+			}
+		}
+		opCtx := context.WithValue(ctx, attemptKey{}, a)
+		cancel := context.CancelFunc(func() {})
+		if timeout != nil {
+			var err error
+			if prevErr != nil {
+				err = ignoreRetryAfter{prevErr}
+			}
+			d := WaitErr(timeout, err, attempt+1, elapsed)
+			if d > 0 {
+				opCtx, cancel = withTimeout(opCtx, clock, d)
+			}
+		}
+		v, err := op(opCtx)
+		if filter != nil {
+			err = filter(err)
+		}
+		// An expired per attempt deadline is a regular failure.
+		timedOut := opCtx.Err() == context.DeadlineExceeded &&
+			ctx.Err() == nil
+		cancel()
+		if err == nil || isIgnore(err) {
+			res.Reason = ReasonSuccess
+			return v, nil
 		}
 		attempt++
 
-		// There is no point in retrying after a context error.
-		if errors.Is(err, context.Canceled) ||
-			errors.Is(err, context.DeadlineExceeded) {
-			return err
+		if history != nil {
+			history.Attempts = append(history.Attempts, AttemptError{
+				Attempt:  attempt,
+				Start:    opStart,
+				Duration: clock.Now().Sub(opStart),
+				Err:      unwrapStop(err),
+			})
 		}
 
 		// Do not retry after an ErrorStop.
-		if err, ok := err.(errorStop); ok {
+		if IsStop(err) {
+			res.Reason = ReasonErrorStop
 			// Return the original error.
-			return err.err
+			return fail(unwrapStop(err))
+		}
+
+		// There is no point in retrying after a context error.
+		if !timedOut && (errors.Is(err, context.Canceled) ||
+			errors.Is(err, context.DeadlineExceeded)) {
+			res.Reason = ReasonContextError
+			return fail(err)
 		}
 
 		// Determine the next wait time.
-		wait := p.Wait(attempt, timeSince(start))
+		wait := WaitErr(p, err, attempt, clock.Now().Sub(start))
 		if wait <= Stop {
-			return err
+			res.Reason = ReasonPolicyStop
+			return fail(err)
+		}
+		if wait < cfg.minWait {
+			wait = cfg.minWait
+		}
+
+		// Avoid waiting past the deadline of ctx, if requested.
+		if deadline, ok := ctx.Deadline(); ok &&
+			cfg.deadlineMode != DeadlineWait {
+			left := deadline.Sub(clock.Now()) - cfg.deadlineReserve
+			if wait > left {
+				if cfg.deadlineMode == DeadlineStop || left <= 0 {
+					res.Reason = ReasonDeadline
+					res.SkippedWait = wait
+					return fail(err)
+				}
+				wait = left
+			}
 		}
 
 		if notify != nil {
 			notify(err, attempt, wait)
 		}
+		prevErr, prevWait = err, wait
 
 		if wait == 0 {
 			// Skip over the tmr.
@@ -142,13 +304,22 @@ func Run(ctx context.Context,
 		}
 
 		// Start the tmr.
-		tmr.Reset(wait)
+		if tmr == nil {
+			tmr = clock.NewTimer(wait)
+		} else {
+			tmr.Reset(wait)
+		}
 
+		waitStart := clock.Now()
 		select {
 		case <-ctx.Done():
+			res.Reason = ReasonContextDone
+			res.Waited += clock.Now().Sub(waitStart)
+			res.SkippedWait = wait
 			// Return the op error.
-			return err
+			return done(err)
 		case <-tmr.GetC():
+			res.Waited += wait
 		}
 	}
 }
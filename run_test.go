@@ -22,7 +22,10 @@ package retry
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand"
+	"runtime"
 	"testing"
 	"time"
 
@@ -35,16 +38,96 @@ func TestRun(t *testing.T) {
 		t.Run(test.Name, func(t *testing.T) { testRun(t, test) })
 	}
 	t.Run("ctx canceled", func(t *testing.T) {
-		useActualTime()
+		ctx, cancel := context.WithCancel(context.Background())
+		testRun(t, runTest{
+			Name:   "ctx canceled",
+			Ctx:    ctx,
+			Clock:  systemClock{},
+			Policy: Constant(time.Second),
+			Op: func() error {
+				cancel()
+				return fmt.Errorf("failed")
+			},
+			Err:         "failed: context canceled",
+			NotifyCount: 2,
+			Attempts:    1,
+			Reason:      ReasonContextDone,
+		})
+	})
+	t.Run("ctx canceled/immediate", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		var count uint
+		testRun(t, runTest{
+			Name:   "ctx canceled/immediate",
+			Ctx:    ctx,
+			Policy: LimitTotal{time.Hour, Immediate{}},
+			Op: func() error {
+				count++
+				if count == 3 {
+					cancel()
+				}
+				return fmt.Errorf("failed")
+			},
+			Err:         "failed: context canceled",
+			NotifyCount: 4,
+			Attempts:    3,
+			Reason:      ReasonContextDone,
+		})
+	})
+	t.Run("ctx canceled/first attempt", func(t *testing.T) {
+		assert := assert.New(t)
 		ctx, cancel := context.WithCancel(context.Background())
 		cancel()
+		var res Result
+		err := Run(ctx, Immediate{}, nil, nil, func() error {
+			t.Error("op called")
+			return nil
+		}, WithResult(&res))
+		assert.Equal(context.Canceled, err)
+		assert.Equal(ReasonContextDone, res.Reason)
+		assert.Equal(uint(0), res.Attempts)
+	})
+	t.Run("ctx canceled/cause", func(t *testing.T) {
+		assert := assert.New(t)
+		errShutdown := fmt.Errorf("shutdown")
+		errOp := fmt.Errorf("failed")
+		ctx, cancel := context.WithCancelCause(context.Background())
+		err := Run(ctx, Immediate{}, nil, nil, func() error {
+			cancel(errShutdown)
+			return fmt.Errorf("op: %w", errOp)
+		})
+		assert.EqualError(err, "op: failed: context canceled: shutdown")
+		assert.True(errors.Is(err, errOp))
+		assert.True(errors.Is(err, context.Canceled))
+		assert.True(errors.Is(err, errShutdown))
+
+		err = Run(ctx, Immediate{}, nil, nil, func() error { return nil })
+		assert.EqualError(err, "context canceled: shutdown")
+		assert.True(errors.Is(err, errShutdown))
+	})
+	t.Run("ctx canceled/history", func(t *testing.T) {
+		assert := assert.New(t)
+		errOp := fmt.Errorf("failed")
+		ctx, cancel := context.WithCancel(context.Background())
+		err := Run(ctx, Immediate{}, nil, nil, func() error {
+			cancel()
+			return errOp
+		}, WithErrorHistory())
+		var history *HistoryError
+		assert.True(errors.As(err, &history))
+		assert.True(errors.Is(err, errOp))
+		assert.True(errors.Is(err, context.Canceled))
+	})
+	t.Run("WithMinWait", func(t *testing.T) {
 		testRun(t, runTest{
-			Name:        "ctx canceled",
-			Ctx:         ctx,
-			Policy:      Constant(time.Second),
+			Name:        "WithMinWait",
+			Policy:      LimitTotal{time.Minute, Immediate{}},
 			Op:          func() error { return fmt.Errorf("failed") },
+			Options:     []Option{WithMinWait(10 * time.Second)},
 			Err:         "failed",
-			NotifyCount: 2,
+			NotifyCount: 7,
+			Waits:       []time.Duration{10 * time.Second},
+			Reason:      ReasonPolicyStop,
 		})
 	})
 }
@@ -60,10 +143,22 @@ func testRun(t *testing.T, test runTest) {
 	notify := func(_ error, _ uint, d time.Duration) {
 		opCount++
 		notified = true
+		if len(test.Waits) > 0 {
+			assert.Contains(test.Waits, d)
+		}
 		//fmt.Println(test.Name, "notify", opCount, d)
 	}
 
-	err := Run(test.Ctx, test.Policy, test.Filter, notify, test.Op)
+	clock := test.Clock
+	if clock == nil {
+		clock = newMockClock()
+	}
+
+	var res Result
+	opts := append([]Option{WithClock(clock), WithResult(&res)},
+		test.Options...)
+	err := Run(test.Ctx, test.Policy, test.Filter, notify, test.Op,
+		opts...)
 
 	if len(test.Err) == 0 {
 		assert.NoError(err)
@@ -73,6 +168,13 @@ func testRun(t *testing.T, test runTest) {
 
 	assert.True(notified, "notified")
 	assert.Equal(test.NotifyCount, opCount)
+	if test.Attempts == 0 {
+		// Unless ctx.Done() is closed, op is called once after each
+		// notify, plus once more.
+		test.Attempts = test.NotifyCount
+	}
+	assert.Equal(test.Attempts, res.Attempts)
+	assert.Equal(test.Reason, res.Reason, res.Reason.String())
 }
 
 func testOp(attempts uint, final error) func() error {
@@ -87,14 +189,19 @@ func testOp(attempts uint, final error) func() error {
 }
 
 type runTest struct {
-	Name   string
-	Ctx    context.Context
-	Policy Policy
-	Filter func(error) error
-	Op     func() error
+	Name    string
+	Ctx     context.Context
+	Policy  Policy
+	Filter  func(error) error
+	Op      func() error
+	Clock   Clock
+	Options []Option
 
 	NotifyCount uint
 	Err         string
+	Waits       []time.Duration
+	Attempts    uint
+	Reason      Reason
 }
 
 var runTests = []runTest{
@@ -108,12 +215,14 @@ var runTests = []runTest{
 			fmt.Errorf("wrapped: %w", context.Canceled)),
 		Err:         "wrapped: " + context.Canceled.Error(),
 		NotifyCount: 5,
+		Reason:      ReasonContextError,
 	}, {
 		Name: "op()==context.DeadlineExceeded",
 		Op: testOp(5,
 			fmt.Errorf("wrapped: %w", context.DeadlineExceeded)),
 		Err:         "wrapped: " + context.DeadlineExceeded.Error(),
 		NotifyCount: 5,
+		Reason:      ReasonContextError,
 	}, {
 		Name: "filter ErrorStop",
 		Op:   testOp(8, nil),
@@ -129,11 +238,364 @@ var runTests = []runTest{
 		}(),
 		NotifyCount: 2,
 		Err:         "filtered",
+		Reason:      ReasonErrorStop,
+	}, {
+		Name: "wrapped ErrorStop",
+		Op: testOp(3, fmt.Errorf("db: %w",
+			ErrorStop(fmt.Errorf("filtered")))),
+		NotifyCount: 3,
+		Err:         "db: filtered",
+		Reason:      ReasonErrorStop,
+	}, {
+		Name:        "ErrorStop context.Canceled",
+		Op:          testOp(3, ErrorStop(context.Canceled)),
+		NotifyCount: 3,
+		Err:         context.Canceled.Error(),
+		Reason:      ReasonErrorStop,
+	}, {
+		Name: "wrapped ErrorIgnore",
+		Op: testOp(3, fmt.Errorf("db: %w",
+			ErrorIgnore(fmt.Errorf("ignored")))),
+		NotifyCount: 3,
 	}, {
 		Name:        "policy stop",
 		Op:          testOp(8, nil),
 		Policy:      LimitAttempts{2, Immediate{}},
 		NotifyCount: 2,
 		Err:         "failed",
+		Reason:      ReasonPolicyStop,
 	},
 }
+
+func TestRunValue(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		assert := assert.New(t)
+		var count int
+		v, err := RunValue(nil, Immediate{}, nil, nil,
+			func() (int, error) {
+				count++
+				if count < 3 {
+					return -count, fmt.Errorf("failed")
+				}
+				return count, nil
+			})
+		assert.NoError(err)
+		assert.Equal(3, v)
+	})
+	t.Run("filtered", func(t *testing.T) {
+		assert := assert.New(t)
+		v, err := RunValue(nil, Immediate{},
+			func(error) error { return nil }, nil,
+			func() (string, error) {
+				return "partial", fmt.Errorf("ignored")
+			})
+		assert.NoError(err)
+		assert.Equal("partial", v)
+	})
+	t.Run("stop", func(t *testing.T) {
+		assert := assert.New(t)
+		v, err := RunValue(nil, LimitAttempts{2, Immediate{}}, nil, nil,
+			func() (string, error) {
+				return "stale", fmt.Errorf("failed")
+			})
+		assert.EqualError(err, "failed")
+		assert.Empty(v)
+	})
+	t.Run("ErrorStop", func(t *testing.T) {
+		assert := assert.New(t)
+		v, err := RunValue(nil, Immediate{}, nil, nil,
+			func() (*int, error) {
+				return new(int), ErrorStop(fmt.Errorf("stop"))
+			})
+		assert.EqualError(err, "stop")
+		assert.Nil(v)
+	})
+}
+
+func TestRunResult(t *testing.T) {
+	assert := assert.New(t)
+	clock := newMockClock()
+	var res Result
+	err := Run(nil, LimitAttempts{4, Linear{time.Second, time.Second}},
+		nil, nil, func() error {
+			clock.now = clock.now.Add(time.Millisecond)
+			return fmt.Errorf("failed")
+		}, WithClock(clock), WithResult(&res))
+	assert.EqualError(err, "failed")
+	assert.Equal(Result{
+		Reason:   ReasonPolicyStop,
+		Attempts: 4,
+		Total:    6*time.Second + 4*time.Millisecond,
+		Waited:   6 * time.Second,
+	}, res)
+	assert.Equal("policy stop", res.Reason.String())
+	assert.Equal("unknown reason", Reason(-1).String())
+}
+
+func TestRunContext(t *testing.T) {
+	blockOp := func(count *int) func(context.Context) error {
+		return func(ctx context.Context) error {
+			*count++
+			<-ctx.Done()
+			return fmt.Errorf("blocked: %w", ctx.Err())
+		}
+	}
+	t.Run("attempt timeout", func(t *testing.T) {
+		assert := assert.New(t)
+		var count int
+		var res Result
+		err := RunContext(nil, LimitAttempts{3, Immediate{}}, nil, nil,
+			blockOp(&count), WithAttemptTimeout(time.Millisecond),
+			WithResult(&res))
+		assert.EqualError(err, "blocked: "+
+			context.DeadlineExceeded.Error())
+		assert.Equal(3, count)
+		assert.Equal(ReasonPolicyStop, res.Reason)
+	})
+	t.Run("attempt timeout/derived", func(t *testing.T) {
+		for _, clock := range []Clock{systemClock{}, newMockClock()} {
+			assert := assert.New(t)
+			var count int
+			var res Result
+			err := RunContext(nil, LimitAttempts{2, Immediate{}}, nil, nil,
+				func(ctx context.Context) error {
+					count++
+					child, cancel := context.WithCancel(ctx)
+					defer cancel()
+					for ctx.Err() == nil {
+						runtime.Gosched()
+					}
+					select {
+					case <-ctx.Done():
+					default:
+						t.Errorf("%T: Err set before Done closed", clock)
+					}
+					<-child.Done()
+					assert.Equal(context.DeadlineExceeded,
+						context.Cause(child), "%T", clock)
+					return fmt.Errorf("blocked: %w", child.Err())
+				}, WithAttemptTimeout(time.Millisecond),
+				WithClock(clock), WithResult(&res))
+			assert.EqualError(err, "blocked: "+
+				context.DeadlineExceeded.Error(), "%T", clock)
+			assert.Equal(2, count, "%T", clock)
+			assert.Equal(ReasonPolicyStop, res.Reason, "%T", clock)
+		}
+	})
+	t.Run("ctx deadline", func(t *testing.T) {
+		assert := assert.New(t)
+		ctx, cancel := context.WithTimeout(context.Background(),
+			time.Millisecond)
+		defer cancel()
+		var count int
+		var res Result
+		err := RunContext(ctx, Immediate{}, nil, nil,
+			blockOp(&count), WithAttemptTimeout(time.Hour),
+			WithResult(&res))
+		assert.EqualError(err, "blocked: "+
+			context.DeadlineExceeded.Error())
+		assert.Equal(1, count)
+		assert.Equal(ReasonContextError, res.Reason)
+	})
+	t.Run("timeout policy", func(t *testing.T) {
+		assert := assert.New(t)
+		clock := stoppedClock{time.Unix(0, 0)}
+		var timeouts []time.Duration
+		v, err := RunValueContext(nil, Immediate{}, nil, nil,
+			func(ctx context.Context) (int, error) {
+				deadline, ok := ctx.Deadline()
+				if !ok {
+					return 0, ErrorStop(fmt.Errorf("no deadline"))
+				}
+				timeouts = append(timeouts, deadline.Sub(clock.now))
+				if len(timeouts) < 3 {
+					return 0, fmt.Errorf("failed")
+				}
+				return len(timeouts), nil
+			}, WithClock(clock), WithAttemptTimeoutPolicy(
+				Linear{time.Second, time.Second}))
+		assert.NoError(err)
+		assert.Equal(3, v)
+		assert.Equal([]time.Duration{
+			time.Second, 2 * time.Second, 3 * time.Second}, timeouts)
+	})
+	t.Run("RetryAfter", func(t *testing.T) {
+		assert := assert.New(t)
+		clock := stoppedClock{time.Unix(0, 0)}
+		errs := []error{RetryAfter(fmt.Errorf("failed"), time.Hour),
+			RetryAfter(fmt.Errorf("failed"), 0), nil}
+		for _, timeout := range []Policy{Constant(10 * time.Second),
+			LimitAttempts{5, Constant(10 * time.Second)}} {
+			var timeouts []time.Duration
+			// Max caps the RetryAfter waits, since clock never fires.
+			err := RunContext(nil, Max{0, Immediate{}}, nil, nil,
+				func(ctx context.Context) error {
+					deadline, ok := ctx.Deadline()
+					if !ok {
+						return ErrorStop(fmt.Errorf("no deadline"))
+					}
+					timeouts = append(timeouts, deadline.Sub(clock.now))
+					return errs[len(timeouts)-1]
+				}, WithClock(clock), WithAttemptTimeoutPolicy(timeout))
+			assert.NoError(err, "%#v", timeout)
+			assert.Equal([]time.Duration{10 * time.Second,
+				10 * time.Second, 10 * time.Second}, timeouts,
+				"%#v", timeout)
+		}
+	})
+	t.Run("no timeout", func(t *testing.T) {
+		assert := assert.New(t)
+		ctx := context.WithValue(context.Background(), t, t)
+		err := RunContext(ctx, Immediate{}, nil, nil,
+			func(opCtx context.Context) error {
+				_, ok := opCtx.Deadline()
+				assert.False(ok)
+				assert.Equal(t, opCtx.Value(t))
+				return nil
+			}, WithAttemptTimeoutPolicy(Immediate{}))
+		assert.NoError(err)
+	})
+}
+
+func TestAttemptFromContext(t *testing.T) {
+	assert := assert.New(t)
+	clock := newMockClock()
+	start := clock.now
+
+	_, ok := AttemptFromContext(context.Background())
+	assert.False(ok)
+
+	var attempts []Attempt
+	err := RunContext(nil, LimitAttempts{3, Linear{time.Second, time.Second}},
+		nil, nil, func(ctx context.Context) error {
+			a, ok := AttemptFromContext(ctx)
+			assert.True(ok)
+			attempts = append(attempts, a)
+			return fmt.Errorf("failed %v", a.Number)
+		}, WithClock(clock))
+	assert.EqualError(err, "failed 3")
+
+	if !assert.Len(attempts, 3) {
+		return
+	}
+	for i := range attempts {
+		final, ok := attempts[i].Final()
+		assert.True(ok)
+		assert.Equal(i == 2, final, "attempt %v", i+1)
+		attempts[i].final = nil
+	}
+	assert.Equal(Attempt{Number: 1, Start: start}, attempts[0])
+
+	assert.EqualError(attempts[1].PrevErr, "failed 1")
+	attempts[1].PrevErr = nil
+	assert.Equal(Attempt{Number: 2, Start: start,
+		Elapsed: time.Second, PrevWait: time.Second}, attempts[1])
+
+	assert.EqualError(attempts[2].PrevErr, "failed 2")
+	attempts[2].PrevErr = nil
+	assert.Equal(Attempt{Number: 3, Start: start,
+		Elapsed: 3 * time.Second, PrevWait: 2 * time.Second},
+		attempts[2])
+}
+
+func TestAttemptFinal(t *testing.T) {
+	t.Run("unpredictable", func(t *testing.T) {
+		assert := assert.New(t)
+		errA := fmt.Errorf("a")
+		for _, policy := range []Policy{
+			Switch{Cases: []Case{{MatchIs(errA),
+				LimitAttempts{3, Immediate{}}}}},
+			LimitAttempts{3, FullJitter{Constant(time.Second)}},
+			LimitAttempts{3, countPolicy{}},
+		} {
+			var n int
+			err := RunContext(nil, policy, nil, nil,
+				func(ctx context.Context) error {
+					n++
+					a, _ := AttemptFromContext(ctx)
+					_, ok := a.Final()
+					assert.False(ok, "%#v", policy)
+					return errA
+				}, WithClock(newMockClock()))
+			assert.Equal(errA, err)
+			assert.Equal(3, n, "%#v", policy)
+		}
+	})
+	t.Run("Rand", func(t *testing.T) {
+		assert := assert.New(t)
+		// Run must not draw from the Rand other than for its waits.
+		newRand := func() Rand { return rand.New(rand.NewSource(1)) }
+		policy := LimitAttempts{4, Randomize{.5, Constant(time.Minute)}.
+			WithRand(newRand, nil)}
+		want := NewSession(Randomize{.5, Constant(time.Minute)}.
+			WithRand(newRand, nil))
+		var waits []time.Duration
+		notify := func(_ error, _ uint, d time.Duration) {
+			waits = append(waits, d)
+		}
+		RunContext(nil, policy, nil, notify, func(ctx context.Context) error {
+			a, _ := AttemptFromContext(ctx)
+			a.Final()
+			return fmt.Errorf("failed")
+		}, WithClock(newMockClock()))
+		assert.Equal([]time.Duration{want.Wait(1, 0), want.Wait(2, 0),
+			want.Wait(3, 0)}, waits)
+	})
+}
+
+func TestRunDeadlineMode(t *testing.T) {
+	for _, test := range []struct {
+		Name   string
+		Mode   DeadlineMode
+		Policy Policy
+		Waits  []time.Duration
+		Result Result
+	}{{
+		Name:   "DeadlineStop",
+		Mode:   DeadlineStop,
+		Policy: Constant(30 * time.Second),
+		Waits: []time.Duration{30 * time.Second, 30 * time.Second,
+			30 * time.Second},
+		Result: Result{Reason: ReasonDeadline, Attempts: 4,
+			Total: 90 * time.Second, Waited: 90 * time.Second,
+			SkippedWait: 30 * time.Second},
+	}, {
+		Name:   "DeadlineShorten",
+		Mode:   DeadlineShorten,
+		Policy: Constant(40 * time.Second),
+		Waits: []time.Duration{40 * time.Second, 40 * time.Second,
+			10 * time.Second},
+		Result: Result{Reason: ReasonDeadline, Attempts: 4,
+			Total: 90 * time.Second, Waited: 90 * time.Second,
+			SkippedWait: 40 * time.Second},
+	}, {
+		Name:   "DeadlineWait",
+		Mode:   DeadlineWait,
+		Policy: LimitAttempts{3, Constant(time.Hour)},
+		Waits:  []time.Duration{time.Hour, time.Hour},
+		Result: Result{Reason: ReasonPolicyStop, Attempts: 3,
+			Total: 2 * time.Hour, Waited: 2 * time.Hour},
+	}} {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			assert := assert.New(t)
+			clock := &mockClock{now: time.Now()}
+			ctx, cancel := context.WithDeadline(context.Background(),
+				clock.now.Add(100*time.Second))
+			defer cancel()
+
+			var waits []time.Duration
+			notify := func(_ error, _ uint, d time.Duration) {
+				waits = append(waits, d)
+			}
+			var res Result
+			err := Run(ctx, test.Policy, nil, notify,
+				func() error { return fmt.Errorf("failed") },
+				WithClock(clock), WithResult(&res),
+				WithDeadlineMode(test.Mode, 10*time.Second))
+			assert.EqualError(err, "failed")
+			assert.Equal(test.Waits, waits)
+			assert.Equal(test.Result, res)
+		})
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2019 Adam S Levy
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package retry
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Schedule is a Policy defined by an explicit list of Waits, where the wait
+// after the first attempt is Waits[0], and so on.
+//
+// Once all Waits have been used, Then is used with attempts rebased to start
+// from 1, so that for example an Exponential Then starts from its Initial
+// wait. If Then is nil, the last of the Waits is repeated. Use Constant(Stop)
+// as Then to stop once all Waits have been used.
+//
+// A Schedule can be parsed from a compact string using ParseSchedule.
+type Schedule struct {
+	Waits []time.Duration
+	Then  Policy
+}
+
+// ParseSchedule parses a Schedule from a comma separated list of durations in
+// the format accepted by time.ParseDuration. If the list ends with "...", the
+// last wait is repeated, otherwise the Schedule stops once all waits have
+// been used.
+//
+// For example, "1s, 5s, 30s, 2m, 10m..." waits 1s, 5s, 30s, 2m, then every
+// 10m, while "1s, 5s, 30s" stops after the fourth attempt.
+func ParseSchedule(s string) (Schedule, error) {
+	var sched Schedule
+	s = strings.TrimSpace(s)
+	repeat := strings.HasSuffix(s, "...")
+	s = strings.TrimSuffix(s, "...")
+	if len(s) == 0 {
+		return sched, fmt.Errorf("retry: empty schedule")
+	}
+	for _, field := range strings.Split(s, ",") {
+		wait, err := time.ParseDuration(strings.TrimSpace(field))
+		if err != nil {
+			return sched, fmt.Errorf("retry: invalid schedule: %w", err)
+		}
+		if wait < 0 {
+			return sched, fmt.Errorf(
+				"retry: invalid schedule: negative wait %v", wait)
+		}
+		sched.Waits = append(sched.Waits, wait)
+	}
+	if !repeat {
+		sched.Then = Constant(Stop)
+	}
+	return sched, nil
+}
+
+// String returns s in the format accepted by ParseSchedule. If s.Then is
+// neither nil nor Constant(Stop), it is described in a form that
+// ParseSchedule does not accept.
+func (s Schedule) String() string {
+	waits := make([]string, len(s.Waits))
+	for i, wait := range s.Waits {
+		waits[i] = wait.String()
+	}
+	str := strings.Join(waits, ", ")
+	switch s.Then {
+	case nil:
+		return str + "..."
+	case Constant(Stop):
+		return str
+	default:
+		return fmt.Sprintf("%v, then %#v", str, s.Then)
+	}
+}
+
+// UnmarshalText parses text using ParseSchedule, so that a Schedule can be
+// loaded from configuration files.
+func (s *Schedule) UnmarshalText(text []byte) error {
+	sched, err := ParseSchedule(string(text))
+	if err != nil {
+		return err
+	}
+	*s = sched
+	return nil
+}
+
+// MarshalText returns s.String(), or an error if s cannot be parsed by
+// ParseSchedule.
+func (s Schedule) MarshalText() ([]byte, error) {
+	if s.Then != nil && s.Then != Policy(Constant(Stop)) {
+		return nil, fmt.Errorf("retry: cannot marshal Schedule.Then")
+	}
+	return []byte(s.String()), nil
+}
+
+// Wait returns s.Waits[attempts-1], or the wait of s.Then once all of s.Waits
+// have been used. If s.Then is nil, the last of s.Waits is returned, or Stop
+// if there are no s.Waits.
+func (s Schedule) Wait(attempts uint, total time.Duration) time.Duration {
+	return s.WaitErr(nil, attempts, total)
+}
+
+// WaitErr is like Wait but passes err to s.Then if it is an ErrorPolicy.
+func (s Schedule) WaitErr(err error,
+	attempts uint, total time.Duration) time.Duration {
+
+	if attempts == 0 {
+		attempts = 1
+	}
+	if n := uint(len(s.Waits)); attempts > n {
+		if s.Then != nil {
+			return WaitErr(s.Then, err, attempts-n, total)
+		}
+		if n == 0 {
+			return Stop
+		}
+		attempts = n
+	}
+	return s.Waits[attempts-1]
+}
+
+// NewSession returns a Schedule using a new session of s.Then.
+func (s Schedule) NewSession() Policy {
+	if s.Then == nil {
+		return s
+	}
+	return Schedule{s.Waits, NewSession(s.Then)}
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 Adam S Levy
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package retry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSchedule(t *testing.T) {
+	for _, test := range []struct {
+		Str      string
+		Schedule Schedule
+		Err      string
+	}{{
+		Str: "1s, 5s, 30s, 2m, 10m...",
+		Schedule: Schedule{Waits: []time.Duration{time.Second,
+			5 * time.Second, 30 * time.Second, 2 * time.Minute,
+			10 * time.Minute}},
+	}, {
+		Str: "1s,500ms",
+		Schedule: Schedule{[]time.Duration{time.Second,
+			500 * time.Millisecond}, Constant(Stop)},
+	}, {
+		Str: "",
+		Err: "retry: empty schedule",
+	}, {
+		Str: "1s,,2s",
+		Err: `retry: invalid schedule: time: invalid duration ""`,
+	}, {
+		Str: "-1s",
+		Err: "retry: invalid schedule: negative wait -1s",
+	}} {
+		test := test
+		t.Run(test.Str, func(t *testing.T) {
+			assert := assert.New(t)
+			var sched Schedule
+			err := sched.UnmarshalText([]byte(test.Str))
+			if len(test.Err) > 0 {
+				assert.EqualError(err, test.Err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(test.Schedule, sched)
+
+			text, err := sched.MarshalText()
+			assert.NoError(err)
+			again, err := ParseSchedule(string(text))
+			assert.NoError(err)
+			assert.Equal(sched, again)
+		})
+	}
+	t.Run("MarshalText/Then", func(t *testing.T) {
+		sched := Schedule{[]time.Duration{time.Second}, Constant(1)}
+		_, err := sched.MarshalText()
+		assert.EqualError(t, err, "retry: cannot marshal Schedule.Then")
+		assert.Equal(t, "1s, then 1", sched.String())
+	})
+}
@@ -0,0 +1,52 @@
+// Copyright 2019 Adam S Levy
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package retry
+
+// SessionPolicy is implemented by Policies that need to keep state across the
+// attempts of a single call to Run, such as the previous wait time or a
+// running error rate.
+//
+// At the start of every call, Run calls NewSession and uses the returned
+// Policy for the remainder of that call. Since the state lives in the session
+// and not in the SessionPolicy itself, the SessionPolicy remains safe to share
+// across repeated or concurrent calls to Run.
+//
+// Wrapper Policies should implement SessionPolicy by returning a copy of
+// themselves that wraps NewSession(wrapped), so that sessions of any wrapped
+// Policy are created. All wrappers in this package do this, so stateless and
+// session based Policies may be composed freely.
+type SessionPolicy interface {
+	Policy
+
+	// NewSession returns a Policy holding fresh state for a single call
+	// to Run. The returned Policy is only used by that call and need not
+	// be safe for concurrent use.
+	NewSession() Policy
+}
+
+// NewSession returns p.NewSession() if p is a SessionPolicy. Otherwise p is
+// returned unchanged.
+func NewSession(p Policy) Policy {
+	if s, ok := p.(SessionPolicy); ok {
+		return s.NewSession()
+	}
+	return p
+}
@@ -0,0 +1,77 @@
+// Copyright 2019 Adam S Levy
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package retry
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countPolicy is a SessionPolicy whose sessions return the number of times
+// Wait was called on them.
+type countPolicy struct{}
+
+func (countPolicy) Wait(uint, time.Duration) time.Duration { return 0 }
+func (countPolicy) NewSession() Policy                     { return new(countSession) }
+
+type countSession int
+
+func (c *countSession) Wait(uint, time.Duration) time.Duration {
+	*c++
+	return time.Duration(*c)
+}
+
+func TestSession(t *testing.T) {
+	t.Run("stateless", func(t *testing.T) {
+		policy := Max{time.Minute, Constant(time.Second)}
+		assert.Equal(t, policy, NewSession(policy).(Max))
+		assert.Equal(t, Constant(time.Second), NewSession(Constant(time.Second)))
+	})
+	t.Run("composed", func(t *testing.T) {
+		assert := assert.New(t)
+		policy := LimitTotal{time.Hour, LimitAttempts{10,
+			Max{time.Minute, Randomize{0, countPolicy{}}}}}
+		a, b := NewSession(policy), NewSession(policy)
+		for i := uint(1); i < 5; i++ {
+			assert.Equal(time.Duration(i), a.Wait(i, 0))
+		}
+		assert.Equal(time.Duration(1), b.Wait(1, 0))
+		assert.Equal(time.Duration(0), policy.Wait(1, 0))
+	})
+	t.Run("Run", func(t *testing.T) {
+		assert := assert.New(t)
+		policy := LimitAttempts{4, Max{time.Minute, countPolicy{}}}
+		for i := 0; i < 3; i++ {
+			var waits []time.Duration
+			notify := func(_ error, _ uint, d time.Duration) {
+				waits = append(waits, d)
+			}
+			err := Run(nil, policy, nil, notify,
+				func() error { return fmt.Errorf("failed") },
+				WithClock(newMockClock()))
+			assert.EqualError(err, "failed")
+			assert.Equal([]time.Duration{1, 2, 3}, waits)
+		}
+	})
+}
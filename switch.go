@@ -0,0 +1,148 @@
+// Copyright 2019 Adam S Levy
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package retry
+
+import (
+	"errors"
+	"time"
+)
+
+// Switch is an ErrorPolicy that routes each wait to a different Policy based
+// on the error returned by op.
+//
+// The Policy of the first Case whose Match returns true for the error is
+// used. If no Case matches, Default is used. If Default is nil, Stop is
+// returned.
+//
+// When used with Run, each Case and the Default keep their own count of
+// attempts, so every class of error has its own backoff curve. For example,
+// the first error matching a Case is passed to its Policy with attempts = 1,
+// regardless of how many other errors occurred before it. The total time is
+// always the total time since Run was called.
+type Switch struct {
+	Cases   []Case
+	Default Policy
+}
+
+// Case is a branch of a Switch. Policy is used for errors for which Match
+// returns true.
+type Case struct {
+	Match func(error) bool
+	Policy
+}
+
+// MatchIs returns a Case.Match function that reports whether errors.Is(err,
+// target).
+func MatchIs(target error) func(error) bool {
+	return func(err error) bool { return errors.Is(err, target) }
+}
+
+// MatchAs returns a Case.Match function that reports whether any error in
+// the chain of err is of type T, as determined by errors.As.
+func MatchAs[T error]() func(error) bool {
+	return func(err error) bool {
+		var target T
+		return errors.As(err, &target)
+	}
+}
+
+// Wait returns the result of s.Default.Wait(attempts, total) or Stop if
+// s.Default is nil.
+func (s Switch) Wait(attempts uint, total time.Duration) time.Duration {
+	return s.WaitErr(nil, attempts, total)
+}
+
+// WaitErr returns the wait of the Policy of the first Case matching err, or
+// the Default if none match.
+//
+// Outside of Run, there is no per Case state and so attempts is passed to the
+// selected Policy directly.
+func (s Switch) WaitErr(err error,
+	attempts uint, total time.Duration) time.Duration {
+
+	p := s.branch(s.match(err))
+	if p == nil {
+		return Stop
+	}
+	return WaitErr(p, err, attempts, total)
+}
+
+// NewSession returns a Policy that counts attempts separately for each Case
+// and the Default for a single call to Run.
+func (s Switch) NewSession() Policy {
+	session := switchSession{
+		Switch:   Switch{make([]Case, len(s.Cases)), s.Default},
+		attempts: make([]uint, len(s.Cases)+1),
+	}
+	for i, c := range s.Cases {
+		session.Cases[i] = Case{c.Match, NewSession(c.Policy)}
+	}
+	if s.Default != nil {
+		session.Default = NewSession(s.Default)
+	}
+	return &session
+}
+
+// match returns the index of the first Case matching err, or len(s.Cases) if
+// none match.
+func (s Switch) match(err error) int {
+	if err == nil {
+		return len(s.Cases)
+	}
+	for i, c := range s.Cases {
+		if c.Match != nil && c.Match(err) {
+			return i
+		}
+	}
+	return len(s.Cases)
+}
+
+// branch returns the Policy of the Case at index i, or the Default if i is
+// out of range.
+func (s Switch) branch(i int) Policy {
+	if i < len(s.Cases) {
+		return s.Cases[i].Policy
+	}
+	return s.Default
+}
+
+// switchSession is the per Run session of Switch.
+type switchSession struct {
+	Switch
+	// attempts holds the count for each Case followed by the Default.
+	attempts []uint
+}
+
+func (s *switchSession) Wait(attempts uint, total time.Duration) time.Duration {
+	return s.WaitErr(nil, attempts, total)
+}
+
+func (s *switchSession) WaitErr(err error,
+	attempts uint, total time.Duration) time.Duration {
+
+	i := s.match(err)
+	s.attempts[i]++
+	p := s.branch(i)
+	if p == nil {
+		return Stop
+	}
+	return WaitErr(p, err, s.attempts[i], total)
+}
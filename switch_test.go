@@ -0,0 +1,100 @@
+// Copyright 2019 Adam S Levy
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testStatusError int
+
+func (e testStatusError) Error() string { return fmt.Sprintf("status %d", int(e)) }
+
+var errTestReset = errors.New("connection reset")
+
+func TestSwitch(t *testing.T) {
+	policy := Switch{
+		Cases: []Case{
+			{MatchIs(errTestReset), Linear{time.Second, time.Second}},
+			{MatchAs[testStatusError](), Exponential{time.Minute, 2}},
+		},
+		Default: LimitAttempts{2, Constant(time.Hour)},
+	}
+	reset := fmt.Errorf("dial: %w", errTestReset)
+	status := fmt.Errorf("get: %w", testStatusError(429))
+	other := fmt.Errorf("other")
+
+	t.Run("stateless", func(t *testing.T) {
+		assert := assert.New(t)
+		assert.Equal(2*time.Second, policy.WaitErr(reset, 2, 0))
+		assert.Equal(2*time.Minute, policy.WaitErr(status, 2, 0))
+		assert.Equal(time.Hour, policy.WaitErr(other, 1, 0))
+		assert.Equal(Stop, policy.WaitErr(other, 2, 0))
+		assert.Equal(time.Hour, policy.Wait(1, 0))
+		assert.Equal(Stop, Switch{}.WaitErr(other, 1, 0))
+
+		// Wrappers pass the error along.
+		wrapped := LimitTotal{time.Hour, Max{90 * time.Second, policy}}
+		assert.Equal(2*time.Second, WaitErr(wrapped, reset, 2, 0))
+		assert.Equal(90*time.Second, WaitErr(wrapped, status, 2, 0))
+	})
+	t.Run("session", func(t *testing.T) {
+		assert := assert.New(t)
+		session := NewSession(policy)
+		for i, test := range []struct {
+			Err  error
+			Wait time.Duration
+		}{
+			{reset, time.Second},
+			{status, time.Minute},
+			{reset, 2 * time.Second},
+			{status, 2 * time.Minute},
+			{other, time.Hour},
+			{status, 4 * time.Minute},
+			{reset, 3 * time.Second},
+			{other, Stop},
+		} {
+			wait := WaitErr(session, test.Err, uint(i+1), 0)
+			assert.Equalf(test.Wait, wait, "index %v", i)
+		}
+	})
+	t.Run("Run", func(t *testing.T) {
+		assert := assert.New(t)
+		errs := []error{status, reset, status, reset, other, other}
+		var waits []time.Duration
+		notify := func(_ error, _ uint, d time.Duration) {
+			waits = append(waits, d)
+		}
+		var i int
+		err := Run(nil, policy, nil, notify, func() error {
+			defer func() { i++ }()
+			return errs[i]
+		}, WithClock(newMockClock()))
+		assert.EqualError(err, "other")
+		assert.Equal([]time.Duration{time.Minute, time.Second,
+			2 * time.Minute, 2 * time.Second, time.Hour}, waits)
+	})
+}
@@ -22,34 +22,31 @@ package retry
 
 import "time"
 
-func init() {
-	useMockTime()
+// mockClock is a Clock whose timers fire immediately, advancing Now by the
+// timer duration.
+type mockClock struct {
+	now time.Time
 }
-func useMockTime() {
-	timeNow = mockNow
-	timeNewTimer = mockNewTimer
-}
-func useActualTime() {
-	timeNow = time.Now
-	timeNewTimer = newTimer
-}
-
-var now = time.Unix(0, 0)
 
-func mockNow() time.Time {
-	return now
+func newMockClock() *mockClock {
+	return &mockClock{now: time.Unix(0, 0)}
 }
 
-type mockTimer struct {
-	C chan time.Time
+func (c *mockClock) Now() time.Time {
+	return c.now
 }
 
-func mockNewTimer(d time.Duration) timer {
-	t := mockTimer{C: make(chan time.Time, 1)}
+func (c *mockClock) NewTimer(d time.Duration) Timer {
+	t := mockTimer{clock: c, C: make(chan time.Time, 1)}
 	t.Reset(d)
 	return &t
 }
 
+type mockTimer struct {
+	clock *mockClock
+	C     chan time.Time
+}
+
 func (t *mockTimer) Reset(d time.Duration) bool {
 	// Clear the channel.
 	select {
@@ -57,8 +54,8 @@ func (t *mockTimer) Reset(d time.Duration) bool {
 	default:
 	}
 	// Advance "Now" and load the channel.
-	now = now.Add(d)
-	t.C <- now
+	t.clock.now = t.clock.now.Add(d)
+	t.C <- t.clock.now
 	return true
 }
 
@@ -73,3 +70,18 @@ func (t *mockTimer) Stop() bool {
 func (t *mockTimer) GetC() <-chan time.Time {
 	return t.C
 }
+
+// stoppedClock is a Clock whose time never advances and whose timers never
+// fire.
+type stoppedClock struct{ now time.Time }
+
+func (c stoppedClock) Now() time.Time { return c.now }
+func (c stoppedClock) NewTimer(time.Duration) Timer {
+	return stoppedTimer{}
+}
+
+type stoppedTimer struct{}
+
+func (stoppedTimer) Reset(time.Duration) bool { return true }
+func (stoppedTimer) Stop() bool               { return true }
+func (stoppedTimer) GetC() <-chan time.Time   { return nil }
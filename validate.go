@@ -0,0 +1,341 @@
+// Copyright 2019 Adam S Levy
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Validator is implemented by Policies that can check their own
+// configuration. Validate calls Validate on any Validator it encounters, so
+// custom Policies may report their own misconfigurations.
+type Validator interface {
+	Policy
+
+	// Validate returns an error describing any misconfiguration of the
+	// Policy, or nil if there is none.
+	Validate() error
+}
+
+// ValidationError describes a single problem found by Validate.
+//
+// Path locates the Policy with the problem within the composed Policy passed
+// to Validate, for example "LimitAttempts.Max.Randomize" or "Sum[1]".
+type ValidationError struct {
+	Path string
+	Err  error
+}
+
+func (v *ValidationError) Error() string {
+	return fmt.Sprintf("retry: %v: %v", v.Path, v.Err)
+}
+
+func (v *ValidationError) Unwrap() error { return v.Err }
+
+// Validate reports misconfigurations of p that would otherwise only surface
+// at runtime, often as tight retry loops. It walks all Policies composed
+// within p, including the Policies wrapped by the wrappers and combinators
+// of this package, and reports problems such as
+//
+//   - invalid fields, such as Exponential{0, .5} or a Randomize Factor of 1.7,
+//   - a nil wrapped Policy,
+//   - a Max or Min wrapped by a Randomize, which may jitter waits past
+//     the Cap or below the Floor,
+//   - no attempt or time limit anywhere, so Run may retry forever.
+//
+// If a Policy is a Validator, its Validate method is called. Other custom
+// Policies are not inspected and are assumed to stop on their own.
+//
+// Each problem is reported as a *ValidationError and all problems are joined
+// into the returned error using errors.Join. If no problems are found, nil is
+// returned.
+func Validate(p Policy) error {
+	var v validator
+	if !v.walk(policyName(p), p, "") {
+		v.report(policyName(p), fmt.Errorf(
+			"no attempt or time limit, so Run may retry forever"))
+	}
+	return errors.Join(v.errs...)
+}
+
+// validator accumulates the problems found while walking a Policy.
+type validator struct {
+	errs []error
+}
+
+func (v *validator) report(path string, err error) {
+	v.errs = append(v.errs, &ValidationError{path, err})
+}
+
+// walk validates p, found at path, and returns whether p is guaranteed to
+// eventually return Stop. If p is wrapped by a Randomize, randomize is the
+// path of that Randomize.
+func (v *validator) walk(path string, p Policy, randomize string) bool {
+	if p == nil {
+		v.report(path, fmt.Errorf("nil Policy"))
+		return true
+	}
+	if val, ok := p.(Validator); ok {
+		if err := val.Validate(); err != nil {
+			v.report(path, err)
+		}
+	}
+	// child validates a Policy wrapped by p.
+	child := func(name string, c Policy) bool {
+		if name == "" {
+			name = policyName(c)
+		}
+		return v.walk(path+"."+name, c, randomize)
+	}
+	// children validates the Policies combined by p and returns whether
+	// any of them stops, which stops p.
+	children := func(ps []Policy) bool {
+		if len(ps) == 0 {
+			v.report(path, fmt.Errorf("no Policies"))
+		}
+		stops := false
+		for i, c := range ps {
+			name := fmt.Sprintf("%v[%v].%v", path, i, policyName(c))
+			if v.walk(name, c, randomize) {
+				stops = true
+			}
+		}
+		return stops
+	}
+
+	switch p := p.(type) {
+	case Immediate:
+		return false
+	case Constant:
+		return time.Duration(p) <= Stop
+	case Linear:
+		if p.Initial < 0 || p.Increment < 0 {
+			v.report(path, fmt.Errorf("negative Initial %v or Increment %v",
+				p.Initial, p.Increment))
+		} else if p.Initial == 0 && p.Increment == 0 {
+			v.report(path, fmt.Errorf("zero Initial and Increment never wait"))
+		}
+		return false
+	case Exponential:
+		v.positive(path, "Initial", p.Initial)
+		if !(p.Multiplier > 1) {
+			v.report(path, fmt.Errorf(
+				"Multiplier %v is not greater than 1, so waits never increase",
+				p.Multiplier))
+		}
+		return false
+	case Fibonacci:
+		v.positive(path, "Initial", p.Initial)
+		return false
+	case Polynomial:
+		v.positive(path, "Initial", p.Initial)
+		if !(p.Degree > 0) {
+			v.report(path, fmt.Errorf(
+				"Degree %v is not greater than 0, so waits never increase",
+				p.Degree))
+		}
+		return false
+	case Logarithmic:
+		v.positive(path, "Initial", p.Initial)
+		return false
+
+	case LimitAttempts:
+		if p.Limit <= 1 {
+			v.report(path, fmt.Errorf("Limit %v allows no retries", p.Limit))
+		}
+		child("", p.Policy)
+		return true
+	case LimitTotal:
+		v.positive(path, "Limit", p.Limit)
+		child("", p.Policy)
+		return true
+	case LimitBudget:
+		v.positive(path, "Limit", p.Limit)
+		if p.Reserve < 0 {
+			v.report(path, fmt.Errorf("negative Reserve %v", p.Reserve))
+		} else if p.Limit > 0 && p.Reserve >= p.Limit {
+			v.report(path, fmt.Errorf(
+				"Reserve %v leaves no budget within Limit %v, so it allows no retries",
+				p.Reserve, p.Limit))
+		}
+		child("", p.Policy)
+		return true
+	case LimitDeadline:
+		if p.Deadline.IsZero() {
+			v.report(path, fmt.Errorf("zero Deadline allows no retries"))
+		}
+		child("", p.Policy)
+		return true
+
+	case Max:
+		if p.Cap < 0 {
+			v.report(path, fmt.Errorf("negative Cap %v", p.Cap))
+		} else if p.Cap == 0 {
+			v.report(path, fmt.Errorf("zero Cap never waits"))
+		}
+		if randomize != "" {
+			v.report(path, fmt.Errorf(
+				"wrapped by %v, so waits may exceed Cap %v; wrap the Randomize with the Max instead",
+				randomize, p.Cap))
+		}
+		return child("", p.Policy)
+	case Min:
+		if p.Floor < 0 {
+			v.report(path, fmt.Errorf("negative Floor %v", p.Floor))
+		}
+		if randomize != "" {
+			v.report(path, fmt.Errorf(
+				"wrapped by %v, so waits may fall below Floor %v; wrap the Randomize with the Min instead",
+				randomize, p.Floor))
+		}
+		return child("", p.Policy)
+	case Offset:
+		return child("", p.Policy)
+	case Scale:
+		if !(p.Factor > 0) {
+			v.report(path, fmt.Errorf(
+				"Factor %v is not greater than 0, so it never waits", p.Factor))
+		}
+		return child("", p.Policy)
+	case Randomize:
+		if !(0 <= p.Factor && p.Factor <= 1) {
+			v.report(path, fmt.Errorf(
+				"Factor %v is not within [0, 1]", p.Factor))
+		}
+		randomize = path
+		return child("", p.Policy)
+	case FullJitter:
+		return child("", p.Policy)
+	case EqualJitter:
+		return child("", p.Policy)
+	case DecorrelatedJitter:
+		v.positive(path, "Base", p.Base)
+		if p.Cap < 0 {
+			v.report(path, fmt.Errorf("negative Cap %v", p.Cap))
+		} else if p.Cap > 0 && p.Cap < p.Base {
+			v.report(path, fmt.Errorf("Cap %v is less than Base %v",
+				p.Cap, p.Base))
+		}
+		return false
+	case randPolicy:
+		return v.walk(path, p.policy, randomize)
+	case Window:
+		if len(p.Ranges) == 0 {
+			v.report(path, fmt.Errorf("no Ranges, so it always stops"))
+		}
+		for i, r := range p.Ranges {
+			if r.Start < 0 || r.Start >= 24*time.Hour ||
+				r.End < 0 || r.End > 24*time.Hour {
+				v.report(path, fmt.Errorf(
+					"Ranges[%v] is not within a day: %v to %v",
+					i, r.Start, r.End))
+			}
+		}
+		return child("", p.Policy) || len(p.Ranges) == 0
+
+	case Sum:
+		return children(p) || len(p) == 0
+	case LargestOf:
+		return children(p) || len(p) == 0
+	case SmallestOf:
+		return children(p) || len(p) == 0
+	case Schedule:
+		for i, wait := range p.Waits {
+			if wait < 0 {
+				v.report(path, fmt.Errorf("negative Waits[%v] %v", i, wait))
+			}
+		}
+		if p.Then == nil {
+			return len(p.Waits) == 0
+		}
+		return child("Then", p.Then)
+	case Phases:
+		if len(p) == 0 {
+			v.report(path, fmt.Errorf("no Phases, so it always stops"))
+			return true
+		}
+		stops := true
+		final := -1
+		for i, phase := range p {
+			name := fmt.Sprintf("%v[%v].%v", path, i,
+				policyName(phase.Policy))
+			s := v.walk(name, phase.Policy, randomize)
+			if final >= 0 {
+				continue
+			}
+			if phase.Attempts == 0 && phase.Duration <= 0 {
+				final, stops = i, s
+			}
+		}
+		if final >= 0 && final < len(p)-1 {
+			v.report(path, fmt.Errorf(
+				"Phases[%v] has no Attempts or Duration, so later Phases are never used",
+				final))
+		}
+		return stops
+	case Switch:
+		stops := true
+		for i, c := range p.Cases {
+			name := fmt.Sprintf("%v.Cases[%v]", path, i)
+			if c.Match == nil {
+				v.report(name, fmt.Errorf("nil Match"))
+			}
+			name += "." + policyName(c.Policy)
+			if !v.walk(name, c.Policy, randomize) {
+				stops = false
+			}
+		}
+		if p.Default != nil && !child("Default."+policyName(p.Default),
+			p.Default) {
+			stops = false
+		}
+		return stops
+	}
+	// Custom Policies are assumed to stop on their own.
+	return true
+}
+
+// positive reports field of the Policy at path if d is not greater than 0.
+func (v *validator) positive(path, field string, d time.Duration) {
+	if d <= 0 {
+		v.report(path, fmt.Errorf("%v %v is not greater than 0", field, d))
+	}
+}
+
+// policyName returns the name of the type of p without its package, for use
+// in a ValidationError Path. If p is nil, "Policy" is returned.
+func policyName(p Policy) string {
+	if p == nil {
+		return "Policy"
+	}
+	if r, ok := p.(randPolicy); ok {
+		return policyName(r.policy)
+	}
+	name := fmt.Sprintf("%T", p)
+	for i := len(name) - 1; i >= 0; i-- {
+		if name[i] == '.' {
+			return name[i+1:]
+		}
+	}
+	return name
+}
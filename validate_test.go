@@ -0,0 +1,152 @@
+// Copyright 2019 Adam S Levy
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package retry
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var validateTests = []struct {
+	Name   string
+	Policy Policy
+	Errs   []string
+}{{
+	Name: "valid",
+	Policy: LimitTotal{time.Hour, LimitAttempts{10, Max{time.Minute,
+		Randomize{.5, Exponential{time.Second, 2}}}}},
+}, {
+	Name: "valid/composed",
+	Policy: Phases{
+		{5, 0, Schedule{Waits: []time.Duration{time.Second}}},
+		{0, 0, LimitBudget{time.Hour, time.Minute, false,
+			SmallestOf{Fibonacci{time.Second}, Constant(time.Minute)}}},
+	},
+}, {
+	Name: "valid/Switch",
+	Policy: Switch{Cases: []Case{
+		{MatchIs(errTestReset), LimitAttempts{3, Immediate{}}},
+	}},
+}, {
+	Name:   "valid/Schedule",
+	Policy: Schedule{[]time.Duration{time.Second}, Constant(Stop)},
+}, {
+	Name:   "nil",
+	Policy: nil,
+	Errs:   []string{"retry: Policy: nil Policy"},
+}, {
+	Name:   "no limit",
+	Policy: Max{time.Minute, Exponential{time.Second, 2}},
+	Errs: []string{"retry: Max: no attempt or time limit, " +
+		"so Run may retry forever"},
+}, {
+	Name:   "Exponential",
+	Policy: LimitAttempts{5, Exponential{0, .5}},
+	Errs: []string{
+		"retry: LimitAttempts.Exponential: Initial 0s is not greater than 0",
+		"retry: LimitAttempts.Exponential: Multiplier 0.5 is not greater " +
+			"than 1, so waits never increase"},
+}, {
+	Name: "Randomize",
+	Policy: LimitAttempts{5, Randomize{1.7,
+		Max{time.Minute, Linear{time.Second, time.Second}}}},
+	Errs: []string{
+		"retry: LimitAttempts.Randomize: Factor 1.7 is not within [0, 1]",
+		"retry: LimitAttempts.Randomize.Max: wrapped by " +
+			"LimitAttempts.Randomize, so waits may exceed Cap 1m0s; " +
+			"wrap the Randomize with the Max instead"},
+}, {
+	Name:   "nil Policy",
+	Policy: LimitAttempts{5, nil},
+	Errs:   []string{"retry: LimitAttempts.Policy: nil Policy"},
+}, {
+	Name:   "LimitAttempts",
+	Policy: LimitAttempts{1, Immediate{}},
+	Errs:   []string{"retry: LimitAttempts: Limit 1 allows no retries"},
+}, {
+	Name: "Switch",
+	Policy: Switch{Cases: []Case{{nil, Immediate{}}},
+		Default: LimitAttempts{3, Immediate{}}},
+	Errs: []string{
+		"retry: Switch.Cases[0]: nil Match",
+		"retry: Switch: no attempt or time limit, so Run may retry forever"},
+}, {
+	Name: "Phases",
+	Policy: Phases{
+		{0, 0, LimitAttempts{3, Constant(time.Second)}},
+		{0, 0, Constant(time.Minute)},
+	},
+	Errs: []string{"retry: Phases: Phases[0] has no Attempts or Duration, " +
+		"so later Phases are never used"},
+}, {
+	Name:   "Sum",
+	Policy: LimitAttempts{3, Sum{Constant(time.Second), nil}},
+	Errs:   []string{"retry: LimitAttempts.Sum[1].Policy: nil Policy"},
+}, {
+	Name: "DecorrelatedJitter",
+	Policy: LimitAttempts{3, DecorrelatedJitter{time.Minute, time.Second}.
+		WithRand(nil, TruncatedNormal{-1})},
+	Errs: []string{
+		"retry: LimitAttempts.DecorrelatedJitter: Cap 1s is less than Base 1m0s"},
+}, {
+	Name:   "Validator",
+	Policy: LimitAttempts{3, validatorPolicy{errTestReset}},
+	Errs:   []string{"retry: LimitAttempts.validatorPolicy: connection reset"},
+}}
+
+func TestValidate(t *testing.T) {
+	for _, test := range validateTests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			assert := assert.New(t)
+			err := Validate(test.Policy)
+			if len(test.Errs) == 0 {
+				assert.NoError(err)
+				return
+			}
+			if !assert.Error(err) {
+				return
+			}
+			errs := err.(interface{ Unwrap() []error }).Unwrap()
+			var msgs []string
+			for _, err := range errs {
+				var v *ValidationError
+				assert.True(errors.As(err, &v))
+				msgs = append(msgs, err.Error())
+			}
+			assert.Equal(test.Errs, msgs)
+		})
+	}
+	t.Run("Unwrap", func(t *testing.T) {
+		err := Validate(LimitAttempts{3, validatorPolicy{errTestReset}})
+		assert.True(t, errors.Is(err, errTestReset))
+	})
+}
+
+// validatorPolicy is a custom Policy that reports Err from Validate.
+type validatorPolicy struct{ Err error }
+
+func (validatorPolicy) Wait(uint, time.Duration) time.Duration { return 0 }
+
+func (v validatorPolicy) Validate() error { return v.Err }
@@ -0,0 +1,185 @@
+// Copyright 2019 Adam S Levy
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package retry
+
+import "time"
+
+// Window wraps a Policy such that attempts only occur within the allowed
+// time Ranges, such as partner business hours.
+//
+// If the wait of Policy would end outside of all Ranges, it is extended to
+// the next opening of a range. If no range opens within the following week,
+// Stop is returned. Thus, a Window with no Ranges always returns Stop.
+//
+// Since the wait may be extended, wrap a Window with LimitTotal, LimitBudget
+// or LimitDeadline to stop when the next opening is too far in the future. A
+// LimitTotal returns Stop if the wait was extended to an opening after its
+// Limit, even with other wrappers of a single Policy, such as LimitAttempts or
+// Max, in between. Combinators such as Sum, Switch and Phases do not pass on
+// whether a wait was extended.
+//
+// The times of day of the Ranges are interpreted in Location, or UTC if
+// Location is nil. Run obtains the current time from its Clock, so that a
+// Clock passed with WithClock applies. Outside of Run, the system clock is
+// used.
+type Window struct {
+	Ranges   []TimeRange
+	Location *time.Location
+	Policy
+}
+
+// TimeRange is a range of time within a day, during which a Window allows
+// attempts.
+//
+// Start and End are offsets from midnight. If End is not after Start, the
+// range continues past midnight until End on the following day, so 22:00 to
+// 06:00 is a valid overnight range.
+//
+// If Weekdays is not empty, the range only opens on the listed days.
+type TimeRange struct {
+	Weekdays   []time.Weekday
+	Start, End time.Duration
+}
+
+// Wait returns the result of w.Policy.Wait(attempts, total), extended if
+// needed so that the wait ends within one of w.Ranges.
+//
+// If w.Policy returns Stop, it is returned directly.
+func (w Window) Wait(attempts uint, total time.Duration) time.Duration {
+	return w.WaitErr(nil, attempts, total)
+}
+
+// WaitErr is like Wait but passes err to w.Policy if it is an
+// ErrorPolicy.
+func (w Window) WaitErr(err error,
+	attempts uint, total time.Duration) time.Duration {
+
+	wait, _ := w.waitExtended(err, attempts, total)
+	return wait
+}
+
+// extender is implemented by Window and by the wrappers of a single Policy,
+// which pass on whether the wait of the Policy they wrap was extended.
+type extender interface {
+	Policy
+	// waitExtended is like WaitErr but also reports whether the wait was
+	// extended to the next opening of a Window.
+	waitExtended(err error,
+		attempts uint, total time.Duration) (time.Duration, bool)
+}
+
+// waitExtended returns the result of WaitErr(p, err, attempts, total) and
+// whether it was extended to the next opening of a Window.
+func waitExtended(p Policy, err error,
+	attempts uint, total time.Duration) (time.Duration, bool) {
+
+	if e, ok := p.(extender); ok {
+		return e.waitExtended(err, attempts, total)
+	}
+	return WaitErr(p, err, attempts, total), false
+}
+
+func (w Window) waitExtended(err error,
+	attempts uint, total time.Duration) (time.Duration, bool) {
+
+	return w.waitClock(systemClock{}, err, attempts, total)
+}
+
+func (w Window) waitClock(clock Clock, err error,
+	attempts uint, total time.Duration) (time.Duration, bool) {
+
+	wait, _ := waitExtended(w.Policy, err, attempts, total)
+	if wait <= Stop {
+		return wait, false
+	}
+	now := clock.Now()
+	end := now.Add(wait)
+	open, ok := w.next(end)
+	if !ok {
+		return Stop, false
+	}
+	return open.Sub(now), !open.Equal(end)
+}
+
+// NewSession returns a Window wrapping a new session of w.Policy.
+func (w Window) NewSession() Policy {
+	return Window{w.Ranges, w.Location, NewSession(w.Policy)}
+}
+
+// next returns t if it is within any of w.Ranges, otherwise the next time a
+// range opens after t. False is returned if no range opens within a week.
+func (w Window) next(t time.Time) (time.Time, bool) {
+	loc := w.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+
+	var next time.Time
+	// Start from the previous day to account for overnight ranges.
+	for day := -1; day <= 7; day++ {
+		y, m, d := t.Date()
+		d += day
+		weekday := time.Date(y, m, d, 0, 0, 0, 0, loc).Weekday()
+		for _, r := range w.Ranges {
+			if !r.on(weekday) {
+				continue
+			}
+			open := timeOfDay(y, m, d, r.Start, loc)
+			end := timeOfDay(y, m, d, r.End, loc)
+			if !end.After(open) {
+				end = timeOfDay(y, m, d+1, r.End, loc)
+			}
+			if !t.Before(open) && t.Before(end) {
+				return t, true
+			}
+			if open.After(t) && (next.IsZero() || open.Before(next)) {
+				next = open
+			}
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// timeOfDay returns the time offset from midnight of the given day in loc.
+// The offset is measured on the wall clock, rather than as elapsed time, so
+// that 09:00 remains 09:00 on days with a daylight saving time transition.
+func timeOfDay(y int, m time.Month, d int,
+	offset time.Duration, loc *time.Location) time.Time {
+
+	return time.Date(y, m, d, int(offset/time.Hour),
+		int(offset%time.Hour/time.Minute),
+		int(offset%time.Minute/time.Second),
+		int(offset%time.Second), loc)
+}
+
+// on reports whether r opens on weekday.
+func (r TimeRange) on(weekday time.Weekday) bool {
+	if len(r.Weekdays) == 0 {
+		return true
+	}
+	for _, wd := range r.Weekdays {
+		if wd == weekday {
+			return true
+		}
+	}
+	return false
+}
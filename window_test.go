@@ -0,0 +1,170 @@
+// Copyright 2019 Adam S Levy
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package retry
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWindow(t *testing.T) {
+	est := time.FixedZone("EST", -5*60*60)
+	// Monday.
+	monday := func(hour, min int) time.Time {
+		return time.Date(2024, 1, 1, hour, min, 0, 0, est)
+	}
+	businessHours := []TimeRange{{
+		Weekdays: []time.Weekday{time.Monday, time.Tuesday,
+			time.Wednesday, time.Thursday, time.Friday},
+		Start: 9 * time.Hour,
+		End:   17 * time.Hour,
+	}}
+	overnight := []TimeRange{{Start: 22 * time.Hour, End: 6 * time.Hour}}
+
+	for _, test := range []struct {
+		Name   string
+		Ranges []TimeRange
+		Now    time.Time
+		Policy Policy
+		Wait   time.Duration
+	}{
+		{"open", businessHours, monday(10, 0),
+			Constant(time.Hour), time.Hour},
+		{"closed/next day", businessHours, monday(16, 30),
+			Constant(time.Hour), 16*time.Hour + 30*time.Minute},
+		{"closed/weekend", businessHours, monday(16, 30).AddDate(0, 0, 4),
+			Constant(time.Hour), 64*time.Hour + 30*time.Minute},
+		{"closed/before open", businessHours, monday(7, 0),
+			Immediate{}, 2 * time.Hour},
+		{"overnight/closed", overnight, monday(7, 0),
+			Constant(time.Minute), 15 * time.Hour},
+		{"overnight/open", overnight, monday(2, 0),
+			Constant(time.Hour), time.Hour},
+		{"stop", businessHours, monday(10, 0),
+			Constant(Stop), Stop},
+		{"no ranges", nil, monday(10, 0),
+			Constant(time.Hour), Stop},
+		{"never opens", []TimeRange{{Weekdays: []time.Weekday{7}}},
+			monday(10, 0), Constant(time.Hour), Stop},
+	} {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			policy := useClock(Window{test.Ranges, est, test.Policy},
+				stoppedClock{test.Now})
+			assert.Equal(t, test.Wait, policy.Wait(1, 0))
+		})
+	}
+
+	friday := monday(16, 30).AddDate(0, 0, 4)
+	t.Run("LimitBudget", func(t *testing.T) {
+		policy := useClock(LimitBudget{24 * time.Hour, 0, false,
+			Window{businessHours, est, Constant(time.Hour)}},
+			stoppedClock{friday})
+		assert.Equal(t, Stop, policy.Wait(1, 0))
+	})
+	t.Run("LimitTotal", func(t *testing.T) {
+		assert := assert.New(t)
+		policy := LimitTotal{24 * time.Hour,
+			Window{businessHours, est, Constant(time.Hour)}}
+		assert.Equal(Stop, useClock(policy, stoppedClock{friday}).Wait(1, 0))
+		p := useClock(policy, stoppedClock{monday(16, 30)})
+		assert.Equal(16*time.Hour+30*time.Minute, p.Wait(1, 0))
+		assert.Equal(Stop, p.Wait(1, 8*time.Hour))
+		p = useClock(policy, stoppedClock{monday(10, 0)})
+		assert.Equal(time.Hour, p.Wait(1, 23*time.Hour+30*time.Minute))
+		assert.Equal(Stop, NewSession(p).Wait(1, 24*time.Hour))
+	})
+	t.Run("LimitTotal/wrapped", func(t *testing.T) {
+		assert := assert.New(t)
+		window := Window{businessHours, est, Constant(time.Hour)}
+		for _, policy := range []Policy{
+			LimitAttempts{10, window},
+			Max{7 * 24 * time.Hour, window},
+			Min{time.Second, window},
+			Offset{time.Second, window},
+			Scale{1, window},
+			Randomize{0, window},
+			Randomize{0, window}.WithRand(nil, nil),
+			LimitBudget{7 * 24 * time.Hour, 0, true, window},
+		} {
+			policy := useClock(LimitTotal{24 * time.Hour, policy},
+				stoppedClock{friday})
+			assert.Equal(Stop, policy.Wait(1, 0), "%#v", policy)
+			assert.Equal(Stop, NewSession(policy).Wait(1, 0),
+				"%#v", policy)
+		}
+	})
+	t.Run("LimitBudget/Truncate", func(t *testing.T) {
+		policy := useClock(LimitBudget{24 * time.Hour, 0, true,
+			LimitAttempts{10, Window{businessHours, est,
+				Constant(time.Hour)}}}, stoppedClock{friday})
+		assert.Equal(t, Stop, policy.Wait(1, 0))
+	})
+	t.Run("LimitDeadline", func(t *testing.T) {
+		assert := assert.New(t)
+		clock := stoppedClock{friday}
+		policy := LimitDeadline{friday.Add(24 * time.Hour),
+			LimitAttempts{10, Window{businessHours, est,
+				Constant(time.Hour)}}}
+		assert.Equal(Stop, useClock(policy, clock).Wait(1, 0))
+		policy.Policy = Constant(48 * time.Hour)
+		assert.Equal(24*time.Hour, useClock(policy, clock).Wait(1, 0))
+	})
+	t.Run("DST", func(t *testing.T) {
+		assert := assert.New(t)
+		newYork, err := time.LoadLocation("America/New_York")
+		if !assert.NoError(err) {
+			return
+		}
+		for _, now := range []time.Time{
+			time.Date(2024, 3, 10, 9, 30, 0, 0, newYork),
+			time.Date(2024, 11, 3, 9, 30, 0, 0, newYork),
+		} {
+			policy := Window{[]TimeRange{{Start: 9 * time.Hour,
+				End: 17 * time.Hour}}, newYork, Immediate{}}
+			assert.Equal(time.Duration(0),
+				useClock(policy, stoppedClock{now}).Wait(1, 0), now)
+			assert.Equal(time.Hour, useClock(policy,
+				stoppedClock{now.Add(-90 * time.Minute)}).Wait(1, 0), now)
+		}
+	})
+	t.Run("UTC", func(t *testing.T) {
+		policy := useClock(Window{Ranges: businessHours, Policy: Immediate{}},
+			stoppedClock{time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)})
+		assert.Equal(t, time.Hour, policy.Wait(1, 0))
+	})
+	t.Run("WithClock", func(t *testing.T) {
+		// The mock clock starts at 19:00 EST on a Wednesday, and the
+		// Window must use it rather than the system clock.
+		var waits []time.Duration
+		notify := func(_ error, _ uint, d time.Duration) {
+			waits = append(waits, d)
+		}
+		Run(nil, LimitAttempts{2, Window{businessHours, est,
+			Constant(time.Hour)}}, nil, notify, func() error {
+			return errTestReset
+		}, WithClock(newMockClock()))
+		assert.Equal(t, []time.Duration{14 * time.Hour}, waits)
+	})
+}